@@ -0,0 +1,186 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	wavFormatImaAdpcm = 0x11
+
+	adpcmSampleRate = 8192
+	// adpcmBlockAlign is the size of one IMA ADPCM wav block, including its
+	// 4 byte header.
+	adpcmBlockAlign = 256
+)
+
+// ExportRawADPCM writes the untouched nibbles of a track into an IMA ADPCM
+// wav file. Each block header carries the decoder state reached by the
+// previous block, so the nibble stream can be recovered bit for bit by
+// ImportRawADPCM.
+func (a *Audio) ExportRawADPCM(writer io.Writer, track PPMAudioTrack) error {
+	src, err := a.Data.trackData(track)
+	if err != nil {
+		return err
+	}
+
+	blockData := adpcmBlockAlign - 4
+	samplesPerBlock := blockData*2 + 1
+
+	data := &bytes.Buffer{}
+	state := &adpcmState{}
+	samples := 0
+
+	for offset := 0; offset < len(src); offset += blockData {
+		block := src[offset:]
+		if len(block) > blockData {
+			block = block[:blockData]
+		}
+
+		binary.Write(data, binary.LittleEndian, int16(state.predictor))
+		binary.Write(data, binary.LittleEndian, byte(state.stepIndex))
+		binary.Write(data, binary.LittleEndian, byte(0))
+		data.Write(block)
+
+		for _, b := range block {
+			state.next(int(b & 0xF))
+			state.next(int(b >> 4))
+		}
+		samples += len(block)*2 + 1
+	}
+
+	dataSize := data.Len()
+	riffSize := 4 + (8 + 20) + (8 + 4) + (8 + dataSize + dataSize%2)
+
+	out := &bytes.Buffer{}
+	out.WriteString("RIFF")
+	binary.Write(out, binary.LittleEndian, uint32(riffSize))
+	out.WriteString("WAVE")
+
+	out.WriteString("fmt ")
+	binary.Write(out, binary.LittleEndian, uint32(20))
+	binary.Write(out, binary.LittleEndian, uint16(wavFormatImaAdpcm))
+	binary.Write(out, binary.LittleEndian, uint16(1))
+	binary.Write(out, binary.LittleEndian, uint32(adpcmSampleRate))
+	binary.Write(out, binary.LittleEndian, uint32(adpcmBlockAlign*adpcmSampleRate/samplesPerBlock))
+	binary.Write(out, binary.LittleEndian, uint16(adpcmBlockAlign))
+	binary.Write(out, binary.LittleEndian, uint16(4))
+	binary.Write(out, binary.LittleEndian, uint16(2))
+	binary.Write(out, binary.LittleEndian, uint16(samplesPerBlock))
+
+	out.WriteString("fact")
+	binary.Write(out, binary.LittleEndian, uint32(4))
+	binary.Write(out, binary.LittleEndian, uint32(samples))
+
+	out.WriteString("data")
+	binary.Write(out, binary.LittleEndian, uint32(dataSize))
+	out.Write(data.Bytes())
+	if dataSize%2 != 0 {
+		out.WriteByte(0)
+	}
+
+	_, err = writer.Write(out.Bytes())
+	return err
+}
+
+// ImportRawADPCM reads an 8192Hz mono IMA ADPCM wav file and stores its
+// nibbles as the given track without transcoding. Flipnote tracks are a
+// single continuous stream, so every block header has to hold the decoder
+// state the previous block ended on, as written by ExportRawADPCM. Files
+// from encoders that reset the state at each block are rejected, since
+// their nibbles would decode differently without the headers.
+func (a *Audio) ImportRawADPCM(reader io.Reader, track PPMAudioTrack) error {
+	wav, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	if len(wav) < 12 || string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		return errors.New("invalid wav file")
+	}
+
+	var blockAlign int
+	var data []byte
+	hasFormat := false
+
+	for offset := 12; offset+8 <= len(wav); {
+		id := string(wav[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(wav[offset+4 : offset+8]))
+		offset += 8
+
+		if offset+size > len(wav) {
+			return fmt.Errorf("wav chunk %q is truncated", id)
+		}
+		chunk := wav[offset : offset+size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return errors.New("invalid wav format chunk")
+			}
+
+			format := binary.LittleEndian.Uint16(chunk[0:2])
+			channels := binary.LittleEndian.Uint16(chunk[2:4])
+			sampleRate := binary.LittleEndian.Uint32(chunk[4:8])
+			bitsPerSample := binary.LittleEndian.Uint16(chunk[14:16])
+
+			if format != wavFormatImaAdpcm || bitsPerSample != 4 {
+				return fmt.Errorf("unsupported wav format 0x%x, expected ima adpcm", format)
+			}
+
+			if channels != 1 {
+				return fmt.Errorf("unsupported channel count %d, expected mono", channels)
+			}
+
+			if sampleRate != adpcmSampleRate {
+				return fmt.Errorf("unsupported sample rate %d, expected %d", sampleRate, adpcmSampleRate)
+			}
+
+			blockAlign = int(binary.LittleEndian.Uint16(chunk[12:14]))
+			hasFormat = true
+		case "data":
+			data = chunk
+		}
+
+		offset += size + size%2
+	}
+
+	if !hasFormat || data == nil {
+		return errors.New("wav file is missing its format or data chunk")
+	}
+
+	if blockAlign <= 4 {
+		return fmt.Errorf("invalid block align %d", blockAlign)
+	}
+
+	raw := make([]byte, 0, len(data))
+	state := &adpcmState{}
+	for offset := 0; offset < len(data); offset += blockAlign {
+		block := data[offset:]
+		if len(block) > blockAlign {
+			block = block[:blockAlign]
+		}
+
+		if len(block) < 4 {
+			return fmt.Errorf("block %d is truncated", offset/blockAlign)
+		}
+
+		predictor := int(int16(binary.LittleEndian.Uint16(block[0:2])))
+		stepIndex := int(block[2])
+		if predictor != state.predictor || stepIndex != state.stepIndex {
+			return fmt.Errorf("block %d does not continue the previous block, the ima adpcm stream was not exported from a flipnote", offset/blockAlign)
+		}
+
+		for _, b := range block[4:] {
+			state.next(int(b & 0xF))
+			state.next(int(b >> 4))
+		}
+
+		raw = append(raw, block[4:]...)
+	}
+
+	return a.setTrackData(track, raw)
+}
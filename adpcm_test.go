@@ -0,0 +1,70 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// adpcmWavData is the offset of the first block in a wav file written by
+// ExportRawADPCM.
+const adpcmWavData = 12 + (8 + 20) + (8 + 4) + 8
+
+func TestRawADPCMRoundTrip(t *testing.T) {
+	file := testFlipnote(30)
+
+	for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+		t.Run(track.String(), func(t *testing.T) {
+			wav := &bytes.Buffer{}
+			if err := file.Audio.ExportRawADPCM(wav, track); err != nil {
+				t.Fatal(err)
+			}
+
+			imported := NewAudio()
+			if err := imported.ImportRawADPCM(bytes.NewReader(wav.Bytes()), track); err != nil {
+				t.Fatal(err)
+			}
+
+			want, _ := file.Audio.Data.trackData(track)
+			got, _ := imported.Data.trackData(track)
+			if !bytes.Equal(got, want) {
+				t.Errorf("imported %d bytes that differ from the %d exported", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestRawADPCMRejectsForeignBlocks(t *testing.T) {
+	file := testFlipnote(30)
+
+	wav := &bytes.Buffer{}
+	if err := file.Audio.ExportRawADPCM(wav, BGM); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		block int
+		// predictor and step index written to the block header, like an
+		// encoder that starts each block from the actual sample
+		predictor int16
+		stepIndex byte
+	}{
+		{"first block does not start at zero", 0, 1200, 0},
+		{"second block resets the step index", 1, 0, 0},
+		{"third block resets the predictor", 2, 321, 20},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := append([]byte(nil), wav.Bytes()...)
+			header := data[adpcmWavData+test.block*adpcmBlockAlign:]
+			binary.LittleEndian.PutUint16(header[0:2], uint16(test.predictor))
+			header[2] = test.stepIndex
+
+			if err := NewAudio().ImportRawADPCM(bytes.NewReader(data), BGM); err == nil {
+				t.Error("foreign ima adpcm file was imported")
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image/png"
+	"io"
+)
+
+type APNGOptions struct {
+	RenderOptions
+	FrameRange
+	// TransparentPaper leaves the paper fully transparent.
+	TransparentPaper bool
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+type pngChunk struct {
+	Type string
+	Data []byte
+}
+
+// ExportAPNG writes the flipnote as an animated PNG. Unlike GIF, frame
+// delays are stored as an exact fraction of a second.
+func (f *PPMFile) ExportAPNG(w io.Writer, opts *APNGOptions) error {
+	if opts == nil {
+		opts = &APNGOptions{}
+	}
+
+	start, end, err := f.frameRange(opts.Start, opts.End)
+	if err != nil {
+		return err
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	// every frame uses the same palette, so the PLTE and tRNS chunks of the
+	// first frame apply to all of them
+	encoder := &png.Encoder{CompressionLevel: png.BestCompression}
+	frameCount := end - start
+	frames := make([][]pngChunk, frameCount)
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	renderer.TransparentPaper = opts.TransparentPaper
+
+	for i := range frames {
+		img, err := renderer.RenderPaletted(f.Frames[start+i], nil)
+		if err != nil {
+			return err
+		}
+
+		buf := &bytes.Buffer{}
+		if err := encoder.Encode(buf, img); err != nil {
+			return err
+		}
+
+		frames[i], err = readPngChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	plays := 1
+	if f.animationFlags(opts.Flags).Loop {
+		plays = 0
+	}
+
+	num, den := framerateFraction(f.Framerate)
+	width, height := 256*scale, 192*scale
+
+	out := &bytes.Buffer{}
+	out.Write(pngSignature)
+
+	sequence := 0
+	for i, chunks := range frames {
+		fctl := &bytes.Buffer{}
+		binary.Write(fctl, binary.BigEndian, uint32(sequence))
+		binary.Write(fctl, binary.BigEndian, uint32(width))
+		binary.Write(fctl, binary.BigEndian, uint32(height))
+		binary.Write(fctl, binary.BigEndian, uint32(0))
+		binary.Write(fctl, binary.BigEndian, uint32(0))
+		// the delay is one frame, den/num seconds
+		binary.Write(fctl, binary.BigEndian, uint16(den))
+		binary.Write(fctl, binary.BigEndian, uint16(num))
+		// no disposal, replace the previous frame
+		fctl.Write([]byte{0, 0})
+		sequence++
+
+		fctlWritten := false
+		for _, chunk := range chunks {
+			switch chunk.Type {
+			case "IHDR":
+				if i != 0 {
+					continue
+				}
+
+				writePngChunk(out, chunk.Type, chunk.Data)
+
+				actl := &bytes.Buffer{}
+				binary.Write(actl, binary.BigEndian, uint32(frameCount))
+				binary.Write(actl, binary.BigEndian, uint32(plays))
+				writePngChunk(out, "acTL", actl.Bytes())
+			case "IDAT":
+				if !fctlWritten {
+					writePngChunk(out, "fcTL", fctl.Bytes())
+					fctlWritten = true
+				}
+
+				if i == 0 {
+					writePngChunk(out, chunk.Type, chunk.Data)
+					continue
+				}
+
+				fdat := make([]byte, 4, 4+len(chunk.Data))
+				binary.BigEndian.PutUint32(fdat, uint32(sequence))
+				writePngChunk(out, "fdAT", append(fdat, chunk.Data...))
+				sequence++
+			case "IEND":
+			default:
+				if i == 0 {
+					writePngChunk(out, chunk.Type, chunk.Data)
+				}
+			}
+		}
+	}
+
+	writePngChunk(out, "IEND", nil)
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func readPngChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("invalid png signature")
+	}
+
+	chunks := make([]pngChunk, 0)
+	for offset := len(pngSignature); offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if offset+12+length > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+
+		chunks = append(chunks, pngChunk{
+			Type: string(data[offset+4 : offset+8]),
+			Data: data[offset+8 : offset+8+length],
+		})
+		offset += 12 + length
+	}
+
+	return chunks, nil
+}
+
+func writePngChunk(w io.Writer, chunkType string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+
+	w.Write([]byte(chunkType))
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,131 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/png"
+	"testing"
+)
+
+func TestExportAPNG(t *testing.T) {
+	tests := []struct {
+		name      string
+		framerate float32
+		loop      bool
+		opts      *APNGOptions
+		frames    int
+		plays     uint32
+	}{
+		{"12 fps", 12, true, nil, 6, 0},
+		{"half fps plays once", 0.5, false, nil, 6, 1},
+		{"30 fps range", 30, true, &APNGOptions{FrameRange: FrameRange{Start: 2, End: 5}, RenderOptions: RenderOptions{Scale: 2}}, 3, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := testFlipnote(6)
+			file.Framerate = test.framerate
+			file.AnimationFlags.Loop = test.loop
+
+			buf := &bytes.Buffer{}
+			if err := file.ExportAPNG(buf, test.opts); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			chunks, err := readPngChunks(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// readPngChunks does not check the crcs
+			offset := len(pngSignature)
+			for _, chunk := range chunks {
+				crc := crc32.ChecksumIEEE(data[offset+4 : offset+8+len(chunk.Data)])
+				if binary.BigEndian.Uint32(data[offset+8+len(chunk.Data):]) != crc {
+					t.Fatalf("%s chunk has a bad crc", chunk.Type)
+				}
+				offset += 12 + len(chunk.Data)
+			}
+
+			scale := 1
+			if test.opts != nil && test.opts.Scale != 0 {
+				scale = test.opts.Scale
+			}
+			num, den := framerateFraction(test.framerate)
+
+			var actl []byte
+			sequence := 0
+			fctls := 0
+			for _, chunk := range chunks {
+				switch chunk.Type {
+				case "acTL":
+					actl = chunk.Data
+				case "fcTL":
+					fctls++
+					if seq := int(binary.BigEndian.Uint32(chunk.Data)); seq != sequence {
+						t.Errorf("fcTL has sequence number %d, want %d", seq, sequence)
+					}
+					sequence++
+
+					width := binary.BigEndian.Uint32(chunk.Data[4:])
+					height := binary.BigEndian.Uint32(chunk.Data[8:])
+					if width != uint32(256*scale) || height != uint32(192*scale) {
+						t.Errorf("fcTL size is %dx%d", width, height)
+					}
+
+					delayNum := binary.BigEndian.Uint16(chunk.Data[20:])
+					delayDen := binary.BigEndian.Uint16(chunk.Data[22:])
+					if int(delayNum) != den || int(delayDen) != num {
+						t.Errorf("fcTL delay is %d/%d, want %d/%d", delayNum, delayDen, den, num)
+					}
+				case "fdAT":
+					if seq := int(binary.BigEndian.Uint32(chunk.Data)); seq != sequence {
+						t.Errorf("fdAT has sequence number %d, want %d", seq, sequence)
+					}
+					sequence++
+				}
+			}
+
+			if actl == nil {
+				t.Fatal("no acTL chunk")
+			}
+			if frames := binary.BigEndian.Uint32(actl); frames != uint32(test.frames) {
+				t.Errorf("acTL has %d frames, want %d", frames, test.frames)
+			}
+			if plays := binary.BigEndian.Uint32(actl[4:]); plays != test.plays {
+				t.Errorf("acTL plays %d times, want %d", plays, test.plays)
+			}
+			if fctls != test.frames {
+				t.Errorf("%d fcTL chunks, want %d", fctls, test.frames)
+			}
+
+			// decoders without APNG support show the first frame
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := 0
+			if test.opts != nil {
+				start = test.opts.Start
+			}
+			want, err := file.renderer(scale, nil, nil).RenderPaletted(file.Frames[start], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if img.Bounds() != want.Bounds() {
+				t.Fatalf("the default image is %v, want %v", img.Bounds(), want.Bounds())
+			}
+			for y := 0; y < want.Rect.Dy(); y++ {
+				for x := 0; x < want.Rect.Dx(); x++ {
+					if !equalColor(img.At(x, y), want.At(x, y)) {
+						t.Fatalf("the default image differs from frame %d at %d,%d", start, x, y)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,852 @@
+package ppmlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+const (
+	asepriteMagic      = 0xA5E0
+	asepriteFrameMagic = 0xF1FA
+
+	asepriteChunkLayer   = 0x2004
+	asepriteChunkCel     = 0x2005
+	asepriteChunkTags    = 0x2018
+	asepriteChunkPalette = 0x2019
+
+	asepriteLayerVisible    = 0x1
+	asepriteLayerEditable   = 0x2
+	asepriteLayerBackground = 0x8
+
+	asepriteCelLinked     = 1
+	asepriteCelCompressed = 2
+)
+
+// asepriteLayers are the exported layers from the bottom up.
+var asepriteLayers = []string{"Paper", "Layer 2", "Layer 1"}
+
+// asepriteCel is the content of one layer in one frame. Pixels are palette
+// indices, with 0 being transparent.
+type asepriteCel struct {
+	X, Y          int
+	Width, Height int
+	Pixels        []byte
+
+	// frame is where the pixels were first written, the target of links
+	frame int
+}
+
+type AsepriteExportOptions struct {
+	// Flags overrides the flipnote's AnimationFlags when set.
+	Flags *AnimationFlags
+}
+
+// ExportAseprite writes the flipnote as an indexed color Aseprite file. The
+// paper is a background layer, Layer1 and Layer2 are separate layers drawn
+// in their pen colors. Layers the AnimationFlags hide are hidden, and the
+// loop setting is stored as the repeat count of a tag over all frames.
+func (f *PPMFile) ExportAseprite(w io.Writer, opts *AsepriteExportOptions) error {
+	if opts == nil {
+		opts = &AsepriteExportOptions{}
+	}
+
+	if len(f.Frames) == 0 {
+		return errors.New("flipnote has no frames")
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	flags := f.animationFlags(opts.Flags)
+	delays := frameDelays(f.Framerate, len(f.Frames), 1000)
+
+	frames := &bytes.Buffer{}
+	previous := make([]*asepriteCel, len(asepriteLayers))
+
+	for i, frame := range f.Frames {
+		chunks := make([][]byte, 0)
+
+		if i == 0 {
+			chunks = append(chunks, asepritePaletteChunk(&PaletteDefault))
+
+			for layer, name := range asepriteLayers {
+				layerFlags := asepriteLayerVisible | asepriteLayerEditable
+				if layer == 0 {
+					layerFlags |= asepriteLayerBackground
+				}
+				if (layer == 1 && flags.HideLayer2) || (layer == 2 && flags.HideLayer1) {
+					layerFlags &^= asepriteLayerVisible
+				}
+
+				chunks = append(chunks, asepriteLayerChunk(name, layerFlags))
+			}
+
+			chunks = append(chunks, asepriteTagsChunk(len(f.Frames), flags.Loop))
+		}
+
+		paper, pen1, pen2 := frameColorIndices(frame)
+		cels := []*asepriteCel{
+			asepritePaperCel(paper + 1),
+			asepriteLayerCel(frame.Layer2, pen2+1),
+			asepriteLayerCel(frame.Layer1, pen1+1),
+		}
+
+		for layer, cel := range cels {
+			if cel == nil {
+				previous[layer] = nil
+				continue
+			}
+
+			chunk, err := asepriteCelChunk(layer, cel, previous[layer])
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+
+			// linked cels point at the frame that holds the pixels
+			if previous[layer] == nil || !cel.equal(previous[layer]) {
+				previous[layer] = cel
+				previous[layer].frame = i
+			}
+		}
+
+		frameData := &bytes.Buffer{}
+		for _, chunk := range chunks {
+			frameData.Write(chunk)
+		}
+
+		binary.Write(frames, binary.LittleEndian, uint32(16+frameData.Len()))
+		binary.Write(frames, binary.LittleEndian, uint16(asepriteFrameMagic))
+		binary.Write(frames, binary.LittleEndian, uint16(len(chunks)))
+		binary.Write(frames, binary.LittleEndian, uint16(delays[i]))
+		binary.Write(frames, binary.LittleEndian, uint16(0))
+		binary.Write(frames, binary.LittleEndian, uint32(len(chunks)))
+		frames.Write(frameData.Bytes())
+	}
+
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.LittleEndian, uint32(128+frames.Len()))
+	binary.Write(header, binary.LittleEndian, uint16(asepriteMagic))
+	binary.Write(header, binary.LittleEndian, uint16(len(f.Frames)))
+	binary.Write(header, binary.LittleEndian, uint16(256))
+	binary.Write(header, binary.LittleEndian, uint16(192))
+	// 8 bits per pixel, indexed
+	binary.Write(header, binary.LittleEndian, uint16(8))
+	// layer opacity is valid
+	binary.Write(header, binary.LittleEndian, uint32(1))
+	binary.Write(header, binary.LittleEndian, uint16(math.Round(1000/float64(f.Framerate))))
+	binary.Write(header, binary.LittleEndian, uint32(0))
+	binary.Write(header, binary.LittleEndian, uint32(0))
+	// the transparent index
+	header.Write([]byte{0, 0, 0, 0})
+	binary.Write(header, binary.LittleEndian, uint16(5))
+	// square pixels
+	header.Write([]byte{1, 1})
+	binary.Write(header, binary.LittleEndian, []int16{0, 0})
+	binary.Write(header, binary.LittleEndian, []uint16{16, 16})
+	header.Write(make([]byte, 84))
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := w.Write(frames.Bytes())
+	return err
+}
+
+func asepriteChunk(chunkType uint16, data []byte) []byte {
+	chunk := make([]byte, 6, 6+len(data))
+	binary.LittleEndian.PutUint32(chunk, uint32(6+len(data)))
+	binary.LittleEndian.PutUint16(chunk[4:], chunkType)
+
+	return append(chunk, data...)
+}
+
+func asepriteString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// asepritePaletteChunk stores the palette shifted up by one, after the
+// transparent index 0.
+func asepritePaletteChunk(palette *Palette) []byte {
+	colors := palette.colors()[:4]
+
+	data := &bytes.Buffer{}
+	binary.Write(data, binary.LittleEndian, uint32(len(colors)+1))
+	binary.Write(data, binary.LittleEndian, uint32(0))
+	binary.Write(data, binary.LittleEndian, uint32(len(colors)))
+	data.Write(make([]byte, 8))
+
+	binary.Write(data, binary.LittleEndian, uint16(0))
+	data.Write([]byte{0, 0, 0, 0})
+	for _, c := range colors {
+		binary.Write(data, binary.LittleEndian, uint16(0))
+		data.Write([]byte{c.R, c.G, c.B, c.A})
+	}
+
+	return asepriteChunk(asepriteChunkPalette, data.Bytes())
+}
+
+func asepriteLayerChunk(name string, flags int) []byte {
+	data := &bytes.Buffer{}
+	binary.Write(data, binary.LittleEndian, uint16(flags))
+	// a normal layer at the top level, with normal blending
+	binary.Write(data, binary.LittleEndian, []uint16{0, 0, 0, 0, 0})
+	data.Write([]byte{255, 0, 0, 0})
+	asepriteString(data, name)
+
+	return asepriteChunk(asepriteChunkLayer, data.Bytes())
+}
+
+func asepriteTagsChunk(frameCount int, loop bool) []byte {
+	repeat := uint16(1)
+	if loop {
+		repeat = 0
+	}
+
+	data := &bytes.Buffer{}
+	binary.Write(data, binary.LittleEndian, uint16(1))
+	data.Write(make([]byte, 8))
+	binary.Write(data, binary.LittleEndian, uint16(0))
+	binary.Write(data, binary.LittleEndian, uint16(frameCount-1))
+	// forward playback
+	data.WriteByte(0)
+	binary.Write(data, binary.LittleEndian, repeat)
+	data.Write(make([]byte, 6))
+	data.Write([]byte{0, 0, 0, 0})
+	asepriteString(data, "Flipnote")
+
+	return asepriteChunk(asepriteChunkTags, data.Bytes())
+}
+
+// asepriteCelChunk writes cel as a compressed image, or as a link when it is
+// the same as the previous one on its layer.
+func asepriteCelChunk(layer int, cel *asepriteCel, previous *asepriteCel) ([]byte, error) {
+	data := &bytes.Buffer{}
+	binary.Write(data, binary.LittleEndian, uint16(layer))
+	binary.Write(data, binary.LittleEndian, int16(cel.X))
+	binary.Write(data, binary.LittleEndian, int16(cel.Y))
+	data.WriteByte(255)
+
+	if previous != nil && cel.equal(previous) {
+		binary.Write(data, binary.LittleEndian, uint16(asepriteCelLinked))
+		data.Write(make([]byte, 7))
+		binary.Write(data, binary.LittleEndian, uint16(previous.frame))
+
+		return asepriteChunk(asepriteChunkCel, data.Bytes()), nil
+	}
+
+	binary.Write(data, binary.LittleEndian, uint16(asepriteCelCompressed))
+	data.Write(make([]byte, 7))
+	binary.Write(data, binary.LittleEndian, uint16(cel.Width))
+	binary.Write(data, binary.LittleEndian, uint16(cel.Height))
+
+	zw := zlib.NewWriter(data)
+	if _, err := zw.Write(cel.Pixels); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return asepriteChunk(asepriteChunkCel, data.Bytes()), nil
+}
+
+func asepritePaperCel(index uint8) *asepriteCel {
+	return &asepriteCel{Width: 256, Height: 192, Pixels: bytes.Repeat([]byte{index}, 256*192)}
+}
+
+// asepriteLayerCel crops a layer to the lines drawn on it. Empty layers have
+// no cel.
+func asepriteLayerCel(layer *Layer, index uint8) *asepriteCel {
+	minX, minY, maxX, maxY := 256, 192, -1, -1
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			if layer.Get(x, y) {
+				minX, minY = minInt(minX, x), minInt(minY, y)
+				maxX, maxY = maxInt(maxX, x), maxInt(maxY, y)
+			}
+		}
+	}
+
+	if maxX < 0 {
+		return nil
+	}
+
+	cel := &asepriteCel{X: minX, Y: minY, Width: maxX - minX + 1, Height: maxY - minY + 1}
+	cel.Pixels = make([]byte, cel.Width*cel.Height)
+	for y := 0; y < cel.Height; y++ {
+		for x := 0; x < cel.Width; x++ {
+			if layer.Get(minX+x, minY+y) {
+				cel.Pixels[y*cel.Width+x] = index
+			}
+		}
+	}
+
+	return cel
+}
+
+func (c *asepriteCel) equal(other *asepriteCel) bool {
+	return c.X == other.X && c.Y == other.Y && c.Width == other.Width && c.Height == other.Height && bytes.Equal(c.Pixels, other.Pixels)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+type AsepriteImportOptions struct {
+	// Author is recorded as the root, parent and current author.
+	Author *Author
+	// Layer1 and Layer2 name the layers imported as Layer1 and Layer2,
+	// "Layer 1" and "Layer 2" when empty. A missing layer is an error if it
+	// was named explicitly, and stays empty otherwise.
+	Layer1 string
+	Layer2 string
+	// Dither draws layers that use more than one color in their most common
+	// pen color, with ordered dithering by how close each pixel is to it.
+	// Without it such layers, and colors far from any flipnote color, are an
+	// error.
+	Dither bool
+}
+
+type asepriteLayer struct {
+	Name  string
+	Flags uint16
+}
+
+type asepriteFile struct {
+	Width, Height int
+	Transparent   byte
+	Palette       color.Palette
+	Layers        []asepriteLayer
+	// Cels are indexed by frame and layer.
+	Cels      []map[int]*asepriteCel
+	Durations []int
+	Loop      bool
+}
+
+// asepriteColors are the colors of all built in palettes, so files drawn
+// with any of them import without dithering.
+var asepriteColors = []color.RGBA{
+	PaletteDefault.White, PaletteDefault.Black, PaletteDefault.Red, PaletteDefault.Blue,
+	PaletteDSi.White, PaletteDSi.Black, PaletteDSi.Red, PaletteDSi.Blue,
+	PaletteHatena.White, PaletteHatena.Black, PaletteHatena.Red, PaletteHatena.Blue,
+}
+
+// asepriteMaxDistance is the squared distance up to which a color counts as
+// a flipnote color.
+const asepriteMaxDistance = 3 * 24 * 24
+
+var bayer4x4 = [16]float64{0, 8, 2, 10, 12, 4, 14, 6, 3, 11, 1, 9, 15, 7, 13, 5}
+
+// FromAseprite builds a flipnote from an indexed color Aseprite file of
+// 256x192 pixels. The paper color of each frame is the more common of black
+// and white on the background layer, white without one. Frame durations are
+// converted to the frame speed closest to the shortest one, repeating frames
+// to keep the timing. Hidden layers and the repeat count of the first tag
+// are kept as AnimationFlags.
+func FromAseprite(r io.Reader, opts *AsepriteImportOptions) (*PPMFile, error) {
+	if opts == nil {
+		opts = &AsepriteImportOptions{}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ase, err := readAseprite(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if ase.Width != 256 || ase.Height != 192 {
+		return nil, fmt.Errorf("aseprite canvas is %dx%d, not 256x192", ase.Width, ase.Height)
+	}
+
+	layer1, err := ase.findLayer(opts.Layer1, "Layer 1")
+	if err != nil {
+		return nil, err
+	}
+
+	layer2, err := ase.findLayer(opts.Layer2, "Layer 2")
+	if err != nil {
+		return nil, err
+	}
+
+	background := -1
+	for i, layer := range ase.Layers {
+		if layer.Flags&asepriteLayerBackground != 0 {
+			background = i
+		}
+	}
+
+	mapping := &asepriteMapping{
+		Nearest: make([]int, len(ase.Palette)),
+		Far:     make([]bool, len(ase.Palette)),
+	}
+	for i, c := range ase.Palette {
+		nearest := nearestColor(c, asepriteColors)
+		if nearest < 0 || byte(i) == ase.Transparent {
+			mapping.Nearest[i] = -1
+			continue
+		}
+
+		mapping.Nearest[i] = nearest % 4
+		mapping.Far[i] = colorDistance(c, asepriteColors[nearest]) > asepriteMaxDistance
+	}
+
+	frames := make([]*Frame, len(ase.Cels))
+	for i, cels := range ase.Cels {
+		frame := NewFrame()
+		frame.PaperColor = PaperColorWhite
+
+		if background >= 0 {
+			if err := ase.readPaper(frame, cels[background], mapping, opts.Dither); err != nil {
+				return nil, fmt.Errorf("frame %d: %v", i, err)
+			}
+		}
+
+		for _, target := range []struct {
+			index int
+			layer *Layer
+		}{{layer1, frame.Layer1}, {layer2, frame.Layer2}} {
+			if target.index < 0 {
+				target.layer.PenColor = PenColorInverted
+				continue
+			}
+
+			name := ase.Layers[target.index].Name
+			if err := ase.readLayer(target.layer, frame.PaperColor, cels[target.index], mapping, opts.Dither); err != nil {
+				return nil, fmt.Errorf("frame %d, layer %q: %v", i, name, err)
+			}
+		}
+
+		frame.FirstByteHeader = 0x80 | byte(frame.PaperColor) | byte(frame.Layer1.PenColor)<<1 | byte(frame.Layer2.PenColor)<<3
+		frames[i] = frame
+	}
+
+	shortest := 0
+	for _, duration := range ase.Durations {
+		if duration > 0 && (shortest == 0 || duration < shortest) {
+			shortest = duration
+		}
+	}
+	if shortest == 0 {
+		shortest = 100
+	}
+
+	speed := nearestFrameSpeed(1000 / float64(shortest))
+	timeline := newTimeline(speed)
+
+	elapsed := 0
+	for i, frame := range frames {
+		elapsed += ase.Durations[i]
+
+		repeats, err := timeline.repeats(float64(elapsed) / 1000)
+		if err != nil {
+			return nil, err
+		}
+		timeline.add(frame, repeats)
+	}
+
+	file, err := createFromFrames(opts.Author, timeline.frames, speed)
+	if err != nil {
+		return nil, err
+	}
+
+	file.AnimationFlags.Loop = ase.Loop
+	if layer1 >= 0 {
+		file.AnimationFlags.HideLayer1 = ase.Layers[layer1].Flags&asepriteLayerVisible == 0
+	}
+	if layer2 >= 0 {
+		file.AnimationFlags.HideLayer2 = ase.Layers[layer2].Flags&asepriteLayerVisible == 0
+	}
+
+	return file, nil
+}
+
+// asepriteMapping maps palette entries to the nearest of white, black, red
+// and blue, -1 being transparent. Far marks entries that are not close to
+// any of them.
+type asepriteMapping struct {
+	Nearest []int
+	Far     []bool
+}
+
+// findLayer returns the index of the named image layer, or -1 if a layer
+// that was not named explicitly is missing.
+func (a *asepriteFile) findLayer(name string, fallback string) (int, error) {
+	explicit := name != ""
+	if !explicit {
+		name = fallback
+	}
+
+	for i, layer := range a.Layers {
+		if layer.Name == name {
+			return i, nil
+		}
+	}
+
+	if explicit {
+		return 0, fmt.Errorf("aseprite file has no layer %q", name)
+	}
+
+	return -1, nil
+}
+
+// readPaper sets the paper color to the more common of white and black.
+func (a *asepriteFile) readPaper(frame *Frame, cel *asepriteCel, mapping *asepriteMapping, dither bool) error {
+	if cel == nil {
+		return nil
+	}
+
+	counts := make([]int, 4)
+	for _, index := range cel.Pixels {
+		if mapping.Nearest[index] < 0 {
+			continue
+		}
+
+		if mapping.Far[index] && !dither {
+			return errors.New("background uses a color that is not a flipnote color")
+		}
+		counts[mapping.Nearest[index]]++
+	}
+
+	if !dither && counts[paletteIndexRed]+counts[paletteIndexBlue] > 0 {
+		return errors.New("background is not plain paper")
+	}
+	if !dither && counts[paletteIndexWhite] > 0 && counts[paletteIndexBlack] > 0 {
+		return errors.New("background mixes black and white")
+	}
+
+	if counts[paletteIndexBlack] > counts[paletteIndexWhite] {
+		frame.PaperColor = PaperColorBlack
+	}
+
+	return nil
+}
+
+// readLayer draws a cel onto layer, picking the pen color from the colors it
+// uses. Pixels in the paper color are left empty.
+func (a *asepriteFile) readLayer(layer *Layer, paper PaperColor, cel *asepriteCel, mapping *asepriteMapping, dither bool) error {
+	layer.PenColor = PenColorInverted
+	if cel == nil {
+		return nil
+	}
+
+	paperIndex, inkIndex := paletteIndexWhite, paletteIndexBlack
+	if paper == PaperColorBlack {
+		paperIndex, inkIndex = paletteIndexBlack, paletteIndexWhite
+	}
+
+	counts := make([]int, 4)
+	for _, index := range cel.Pixels {
+		mapped := mapping.Nearest[index]
+		if mapped < 0 {
+			continue
+		}
+
+		if mapping.Far[index] {
+			if !dither {
+				return errors.New("layer uses a color that is not a flipnote color")
+			}
+
+			// a color only near the paper is still a shade of the ink
+			if mapped == paperIndex {
+				mapped = inkIndex
+			}
+		}
+		counts[mapped]++
+	}
+
+	pen := -1
+	for i, count := range counts {
+		if i == paperIndex || count == 0 {
+			continue
+		}
+
+		if pen >= 0 && !dither {
+			return errors.New("layer uses more than one pen color")
+		}
+		if pen < 0 || count > counts[pen] {
+			pen = i
+		}
+	}
+
+	if pen < 0 {
+		return nil
+	}
+
+	switch pen {
+	case paletteIndexRed:
+		layer.PenColor = PenColorRed
+	case paletteIndexBlue:
+		layer.PenColor = PenColorBlue
+	}
+
+	penColor := PaletteDefault.colors()[pen]
+	paperColor := PaletteDefault.colors()[paperIndex]
+
+	for y := 0; y < cel.Height; y++ {
+		for x := 0; x < cel.Width; x++ {
+			px, py := cel.X+x, cel.Y+y
+			if px < 0 || py < 0 || px >= 256 || py >= 192 {
+				continue
+			}
+
+			index := cel.Pixels[y*cel.Width+x]
+			if mapping.Nearest[index] < 0 {
+				continue
+			}
+
+			if !dither {
+				if mapping.Nearest[index] == pen {
+					layer.Set(px, py, true)
+				}
+				continue
+			}
+
+			coverage := colorCoverage(a.Palette[index], paperColor, penColor)
+			if coverage > (bayer4x4[(py%4)*4+px%4]+0.5)/16 {
+				layer.Set(px, py, true)
+			}
+		}
+	}
+
+	return nil
+}
+
+// colorCoverage returns how far c lies from paper towards pen, from 0 to 1,
+// scaled by its alpha.
+func colorCoverage(c color.Color, paper, pen color.RGBA) float64 {
+	r, g, b, a := c.RGBA()
+	cr, cg, cb := float64(r>>8), float64(g>>8), float64(b>>8)
+
+	dr, dg, db := float64(pen.R)-float64(paper.R), float64(pen.G)-float64(paper.G), float64(pen.B)-float64(paper.B)
+	length := dr*dr + dg*dg + db*db
+	if length == 0 {
+		return 0
+	}
+
+	t := ((cr-float64(paper.R))*dr + (cg-float64(paper.G))*dg + (cb-float64(paper.B))*db) / length
+	t = math.Max(0, math.Min(1, t))
+
+	return t * float64(a) / 0xFFFF
+}
+
+func colorDistance(c color.Color, other color.RGBA) int {
+	r, g, b, _ := c.RGBA()
+	dr := int(r>>8) - int(other.R)
+	dg := int(g>>8) - int(other.G)
+	db := int(b>>8) - int(other.B)
+
+	return dr*dr + dg*dg + db*db
+}
+
+// readAseprite parses the parts of an indexed color Aseprite file needed
+// to rebuild frames: the palette, layers, cels, durations and tags.
+func readAseprite(data []byte) (*asepriteFile, error) {
+	if len(data) < 128 || binary.LittleEndian.Uint16(data[4:]) != asepriteMagic {
+		return nil, errors.New("invalid aseprite signature")
+	}
+
+	if depth := binary.LittleEndian.Uint16(data[12:]); depth != 8 {
+		return nil, fmt.Errorf("aseprite file is not indexed color (%d bits per pixel)", depth)
+	}
+
+	frameCount := int(binary.LittleEndian.Uint16(data[6:]))
+	ase := &asepriteFile{
+		Width:       int(binary.LittleEndian.Uint16(data[8:])),
+		Height:      int(binary.LittleEndian.Uint16(data[10:])),
+		Transparent: data[28],
+		Palette:     make(color.Palette, 256),
+		Cels:        make([]map[int]*asepriteCel, frameCount),
+		Durations:   make([]int, frameCount),
+		Loop:        true,
+	}
+	for i := range ase.Palette {
+		ase.Palette[i] = color.RGBA{}
+	}
+
+	offset := 128
+	hasTags := false
+
+	for i := 0; i < frameCount; i++ {
+		if offset+16 > len(data) {
+			return nil, errors.New("aseprite file is truncated")
+		}
+
+		frameSize := int(binary.LittleEndian.Uint32(data[offset:]))
+		if binary.LittleEndian.Uint16(data[offset+4:]) != asepriteFrameMagic || frameSize < 16 || offset+frameSize > len(data) {
+			return nil, fmt.Errorf("invalid aseprite frame %d", i)
+		}
+
+		chunkCount := int(binary.LittleEndian.Uint32(data[offset+12:]))
+		if chunkCount == 0 {
+			chunkCount = int(binary.LittleEndian.Uint16(data[offset+6:]))
+		}
+
+		ase.Durations[i] = int(binary.LittleEndian.Uint16(data[offset+8:]))
+		ase.Cels[i] = make(map[int]*asepriteCel)
+
+		chunks := data[offset+16 : offset+frameSize]
+		for c := 0; c < chunkCount; c++ {
+			if len(chunks) < 6 {
+				return nil, fmt.Errorf("invalid aseprite chunk in frame %d", i)
+			}
+
+			chunkSize := int(binary.LittleEndian.Uint32(chunks))
+			if chunkSize < 6 || chunkSize > len(chunks) {
+				return nil, fmt.Errorf("invalid aseprite chunk in frame %d", i)
+			}
+
+			chunkType := binary.LittleEndian.Uint16(chunks[4:])
+			body := chunks[6:chunkSize]
+			chunks = chunks[chunkSize:]
+
+			var err error
+			switch chunkType {
+			case asepriteChunkPalette:
+				err = ase.readPaletteChunk(body)
+			case asepriteChunkLayer:
+				err = ase.readLayerChunk(body)
+			case asepriteChunkCel:
+				err = ase.readCelChunk(body, i)
+			case asepriteChunkTags:
+				if !hasTags && len(body) >= 17 && binary.LittleEndian.Uint16(body) > 0 {
+					ase.Loop = binary.LittleEndian.Uint16(body[15:]) == 0
+					hasTags = true
+				}
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("frame %d: %v", i, err)
+			}
+		}
+
+		offset += frameSize
+	}
+
+	return ase, nil
+}
+
+func (a *asepriteFile) readPaletteChunk(body []byte) error {
+	if len(body) < 20 {
+		return errors.New("invalid palette chunk")
+	}
+
+	first := int(binary.LittleEndian.Uint32(body[4:]))
+	last := int(binary.LittleEndian.Uint32(body[8:]))
+	if first > last || last > 255 {
+		return errors.New("invalid palette chunk")
+	}
+
+	entries := body[20:]
+	for i := first; i <= last; i++ {
+		if len(entries) < 6 {
+			return errors.New("invalid palette chunk")
+		}
+
+		flags := binary.LittleEndian.Uint16(entries)
+		a.Palette[i] = color.NRGBA{entries[2], entries[3], entries[4], entries[5]}
+		entries = entries[6:]
+
+		// the entry has a name
+		if flags&1 != 0 {
+			if len(entries) < 2 || len(entries) < 2+int(binary.LittleEndian.Uint16(entries)) {
+				return errors.New("invalid palette chunk")
+			}
+			entries = entries[2+int(binary.LittleEndian.Uint16(entries)):]
+		}
+	}
+
+	return nil
+}
+
+func (a *asepriteFile) readLayerChunk(body []byte) error {
+	if len(body) < 18 {
+		return errors.New("invalid layer chunk")
+	}
+
+	nameLength := int(binary.LittleEndian.Uint16(body[16:]))
+	if len(body) < 18+nameLength {
+		return errors.New("invalid layer chunk")
+	}
+
+	a.Layers = append(a.Layers, asepriteLayer{
+		Name:  string(body[18 : 18+nameLength]),
+		Flags: binary.LittleEndian.Uint16(body),
+	})
+
+	return nil
+}
+
+func (a *asepriteFile) readCelChunk(body []byte, frame int) error {
+	if len(body) < 16 {
+		return errors.New("invalid cel chunk")
+	}
+
+	layer := int(binary.LittleEndian.Uint16(body))
+	cel := &asepriteCel{
+		X:     int(int16(binary.LittleEndian.Uint16(body[2:]))),
+		Y:     int(int16(binary.LittleEndian.Uint16(body[4:]))),
+		frame: frame,
+	}
+
+	switch binary.LittleEndian.Uint16(body[7:]) {
+	case asepriteCelLinked:
+		if len(body) < 18 {
+			return errors.New("invalid cel chunk")
+		}
+
+		source := int(binary.LittleEndian.Uint16(body[16:]))
+		if source >= frame || a.Cels[source][layer] == nil {
+			return errors.New("cel links to a missing cel")
+		}
+
+		a.Cels[frame][layer] = a.Cels[source][layer]
+		return nil
+	case 0, asepriteCelCompressed:
+		if len(body) < 20 {
+			return errors.New("invalid cel chunk")
+		}
+
+		cel.Width = int(binary.LittleEndian.Uint16(body[16:]))
+		cel.Height = int(binary.LittleEndian.Uint16(body[18:]))
+		cel.Pixels = body[20:]
+
+		if binary.LittleEndian.Uint16(body[7:]) == asepriteCelCompressed {
+			zr, err := zlib.NewReader(bytes.NewReader(body[20:]))
+			if err != nil {
+				return err
+			}
+
+			cel.Pixels = make([]byte, cel.Width*cel.Height)
+			if _, err := io.ReadFull(zr, cel.Pixels); err != nil {
+				return err
+			}
+		}
+
+		if len(cel.Pixels) < cel.Width*cel.Height {
+			return errors.New("cel is truncated")
+		}
+	default:
+		return errors.New("unsupported cel type")
+	}
+
+	a.Cels[frame][layer] = cel
+	return nil
+}
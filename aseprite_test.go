@@ -0,0 +1,162 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+func TestAsepriteRoundTrip(t *testing.T) {
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for speed := byte(1); speed <= 8; speed++ {
+		file := testFlipnote(5)
+		file.Frames[2].PaperColor = PaperColorWhite
+		file.Frames[3].Layer1.PenColor = PenColorBlue
+		file.Framerate = ppmFramerates[speed]
+		file.Audio.Header.CurrentFrameSpeed = speed
+
+		buf := &bytes.Buffer{}
+		if err := file.ExportAseprite(buf, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := FromAseprite(buf, &AsepriteImportOptions{Author: author})
+		if err != nil {
+			t.Fatalf("speed %d: %v", speed, err)
+		}
+
+		if imported.Audio.Header.CurrentFrameSpeed != speed || imported.Framerate != file.Framerate {
+			t.Errorf("speed %d: imported speed %d at %v fps", speed, imported.Audio.Header.CurrentFrameSpeed, imported.Framerate)
+		}
+
+		if len(imported.Frames) != len(file.Frames) {
+			t.Fatalf("speed %d: imported %d frames, want %d", speed, len(imported.Frames), len(file.Frames))
+		}
+
+		for i, frame := range file.Frames {
+			got := imported.Frames[i]
+			if got.PaperColor != frame.PaperColor {
+				t.Errorf("speed %d, frame %d: paper is %d, want %d", speed, i, got.PaperColor, frame.PaperColor)
+			}
+			if got.Layer1.PenColor != frame.Layer1.PenColor || got.Layer2.PenColor != frame.Layer2.PenColor {
+				t.Errorf("speed %d, frame %d: pens are %d and %d, want %d and %d", speed, i,
+					got.Layer1.PenColor, got.Layer2.PenColor, frame.Layer1.PenColor, frame.Layer2.PenColor)
+			}
+			if !bytes.Equal(got.Layer1.layerData, frame.Layer1.layerData) || !bytes.Equal(got.Layer2.layerData, frame.Layer2.layerData) {
+				t.Errorf("speed %d, frame %d: layers differ", speed, i)
+			}
+		}
+	}
+}
+
+// testAseprite builds a single frame Aseprite file with one layer, "Layer
+// 1", holding cel.
+func testAseprite(t *testing.T, width, height int, palette *Palette, cel *asepriteCel) []byte {
+	celChunk, err := asepriteCelChunk(0, cel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := [][]byte{asepritePaletteChunk(palette), asepriteLayerChunk("Layer 1", asepriteLayerVisible), celChunk}
+	frame := &bytes.Buffer{}
+	for _, chunk := range chunks {
+		frame.Write(chunk)
+	}
+
+	data := &bytes.Buffer{}
+	header := make([]byte, 128)
+	binary.LittleEndian.PutUint32(header, uint32(128+16+frame.Len()))
+	binary.LittleEndian.PutUint16(header[4:], asepriteMagic)
+	binary.LittleEndian.PutUint16(header[6:], 1)
+	binary.LittleEndian.PutUint16(header[8:], uint16(width))
+	binary.LittleEndian.PutUint16(header[10:], uint16(height))
+	binary.LittleEndian.PutUint16(header[12:], 8)
+	data.Write(header)
+
+	frameHeader := make([]byte, 16)
+	binary.LittleEndian.PutUint32(frameHeader, uint32(16+frame.Len()))
+	binary.LittleEndian.PutUint16(frameHeader[4:], asepriteFrameMagic)
+	binary.LittleEndian.PutUint16(frameHeader[8:], 100)
+	binary.LittleEndian.PutUint32(frameHeader[12:], uint32(len(chunks)))
+	data.Write(frameHeader)
+	data.Write(frame.Bytes())
+
+	return data.Bytes()
+}
+
+func TestFromAsepriteUnrepresentable(t *testing.T) {
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// palette indices are shifted up by one, 0 is transparent
+	const red, blue = paletteIndexRed + 1, paletteIndexBlue + 1
+
+	// three quarters red and one quarter blue, which lies halfway between
+	// the paper and red
+	twoPens := asepritePaperCel(red)
+	for i := 0; i < len(twoPens.Pixels); i += 4 {
+		twoPens.Pixels[i] = blue
+	}
+
+	// a dark gray three quarters of the way from white to black, in place
+	// of blue
+	gray := PaletteDefault
+	gray.Blue = color.RGBA{0x40, 0x40, 0x40, 0xFF}
+
+	tests := []struct {
+		name    string
+		palette *Palette
+		cel     *asepriteCel
+		// pen and drawn are what dithering gives, drawn being the share of
+		// set pixels
+		pen   PenColor
+		drawn float64
+	}{
+		{"two pen colors", &PaletteDefault, twoPens, PenColorRed, 0.875},
+		{"not a flipnote color", &gray, asepritePaperCel(blue), PenColorInverted, 0.75},
+	}
+
+	for _, test := range tests {
+		data := testAseprite(t, 256, 192, test.palette, test.cel)
+
+		if _, err := FromAseprite(bytes.NewReader(data), &AsepriteImportOptions{Author: author}); err == nil {
+			t.Errorf("%s: imported without dithering", test.name)
+		}
+
+		file, err := FromAseprite(bytes.NewReader(data), &AsepriteImportOptions{Author: author, Dither: true})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		layer := file.Frames[0].Layer1
+		if layer.PenColor != test.pen {
+			t.Errorf("%s: pen is %d, want %d", test.name, layer.PenColor, test.pen)
+		}
+
+		set := 0
+		for y := 0; y < 192; y++ {
+			for x := 0; x < 256; x++ {
+				if layer.Get(x, y) {
+					set++
+				}
+			}
+		}
+		if drawn := float64(set) / (256 * 192); drawn < test.drawn-0.05 || drawn > test.drawn+0.05 {
+			t.Errorf("%s: %.2f of the pixels are drawn, want about %.2f", test.name, drawn, test.drawn)
+		}
+	}
+
+	wrongSize := testAseprite(t, 128, 96, &PaletteDefault, &asepriteCel{Width: 128, Height: 96, Pixels: make([]byte, 128*96)})
+	for _, dither := range []bool{false, true} {
+		if _, err := FromAseprite(bytes.NewReader(wrongSize), &AsepriteImportOptions{Author: author, Dither: dither}); err == nil {
+			t.Errorf("a 128x96 canvas was imported with dither %v", dither)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package ppmlib
 
 import (
+	"errors"
+	"fmt"
 	"io"
-
-	"github.com/go-audio/audio"
-	"github.com/go-audio/wav"
+	"os"
+	"path/filepath"
 )
 
 type SoundHeader struct {
@@ -36,6 +37,46 @@ func NewSoundData() *SoundData {
 	}
 }
 
+func (s *SoundData) trackData(track PPMAudioTrack) ([]byte, error) {
+	switch track {
+	case BGM:
+		return s.RawBGM, nil
+	case SE1:
+		return s.RawSE1, nil
+	case SE2:
+		return s.RawSE2, nil
+	case SE3:
+		return s.RawSE3, nil
+	case Master:
+		return nil, errors.New("the master mix has no raw track data")
+	}
+
+	return nil, errors.New("invalid track")
+}
+
+func (a *Audio) setTrackData(track PPMAudioTrack, data []byte) error {
+	switch track {
+	case BGM:
+		a.Data.RawBGM = data
+		a.Header.BGMTrackSize = uint32(len(data))
+	case SE1:
+		a.Data.RawSE1 = data
+		a.Header.SE1TrackSize = uint32(len(data))
+	case SE2:
+		a.Data.RawSE2 = data
+		a.Header.SE2TrackSize = uint32(len(data))
+	case SE3:
+		a.Data.RawSE3 = data
+		a.Header.SE3TrackSize = uint32(len(data))
+	case Master:
+		return errors.New("the master mix has no raw track data")
+	default:
+		return errors.New("invalid track")
+	}
+
+	return nil
+}
+
 type Audio struct {
 	Header *SoundHeader
 	Data   *SoundData
@@ -60,29 +101,89 @@ func (a *Audio) Export(reader io.WriteSeeker, flipnote *PPMFile, sampleRate int)
 		return err
 	}
 
-	intBuffer := make([]int, len(decoded))
-	for i, v := range decoded {
-		intBuffer[i] = int(v)
+	return encodeWav(reader, decoded, sampleRate)
+}
+
+// ExportFormat writes the mixed master in the given format. Unlike Export it
+// does not need a seekable writer, so it can be used to pipe audio into
+// other tools.
+func (a *Audio) ExportFormat(writer io.Writer, flipnote *PPMFile, sampleRate int, format AudioFormat) error {
+	decoder := NewAudioDecoder(flipnote)
+
+	if sampleRate == 0 {
+		sampleRate = 32768
 	}
 
-	aa := &audio.IntBuffer{
-		Format: &audio.Format{
-			NumChannels: 1,
-			SampleRate:  sampleRate,
-		},
-		Data:           intBuffer,
-		SourceBitDepth: 16,
+	decoded, err := decoder.GetAudioMasterPcm(sampleRate)
+	if err != nil {
+		return err
 	}
 
-	e := wav.NewEncoder(reader, sampleRate, 16, 1, 1)
+	return EncodePcm(writer, decoded, sampleRate, format)
+}
+
+// ExportTrack writes a single track, or the Master mix, as a wav file. Every
+// track spans the whole flipnote, with sound effects placed on every frame
+// that triggers them.
+func (a *Audio) ExportTrack(writer io.WriteSeeker, flipnote *PPMFile, track PPMAudioTrack, sampleRate int) error {
+	return a.ExportTrackFormat(writer, flipnote, track, sampleRate, AudioFormatWAV)
+}
 
-	if err := e.Write(aa); err != nil {
-		return err
+// ExportTrackFormat writes a single track like ExportTrack, in the given
+// format.
+func (a *Audio) ExportTrackFormat(writer io.Writer, flipnote *PPMFile, track PPMAudioTrack, sampleRate int, format AudioFormat) error {
+	decoder := NewAudioDecoder(flipnote)
+
+	if sampleRate == 0 {
+		sampleRate = 32768
 	}
 
-	if err := e.Close(); err != nil {
+	decoded, err := decoder.GetAudioStemPcm(sampleRate, track)
+	if err != nil {
 		return err
 	}
 
+	return EncodePcm(writer, decoded, sampleRate, format)
+}
+
+// ExportStems writes every non-empty track of the flipnote to dir as wav
+// files, named after its current filename and the track, e.g.
+// "<filename>_SE1.wav". The stems have the same length and line up when
+// laid back together.
+func (f *PPMFile) ExportStems(dir string) error {
+	return f.ExportStemsFormat(dir, AudioFormatWAV)
+}
+
+// ExportStemsFormat writes the stems like ExportStems, in the given format
+// and with its extension.
+func (f *PPMFile) ExportStemsFormat(dir string, format AudioFormat) error {
+	extension := format.extension()
+	if extension == "" {
+		return errors.New("invalid audio format")
+	}
+
+	decoder := NewAudioDecoder(f)
+
+	for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+		if !decoder.hasTrack(track) {
+			continue
+		}
+
+		name := fmt.Sprintf("%s_%s.%s", f.CurrentFilename, track, extension)
+		file, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+
+		err = f.Audio.ExportTrackFormat(file, f, track, 0, format)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,79 @@
+package ppmlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportStems(t *testing.T) {
+	file := testFlipnote(30)
+
+	for _, test := range []struct {
+		format    AudioFormat
+		extension string
+	}{
+		{AudioFormatWAV, "wav"},
+		{AudioFormatWAVFloat32, "wav"},
+		{AudioFormatPCM, "pcm"},
+		{AudioFormatAIFF, "aiff"},
+		{AudioFormatFLAC, "flac"},
+	} {
+		t.Run(test.format.String(), func(t *testing.T) {
+			dir := t.TempDir()
+
+			if err := file.ExportStemsFormat(dir, test.format); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// SE3 is empty and left out
+			want := []string{"BGM", "SE1", "SE2"}
+			if len(entries) != len(want) {
+				t.Fatalf("wrote %d stems, want %d", len(entries), len(want))
+			}
+
+			size := int64(-1)
+			for _, track := range want {
+				info, err := os.Stat(filepath.Join(dir, file.CurrentFilename.String()+"_"+track+"."+test.extension))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// flac stems are compressed and differ in size
+				if test.format != AudioFormatFLAC && size >= 0 && info.Size() != size {
+					t.Errorf("%s stem is %d bytes, the others %d", track, info.Size(), size)
+				}
+				size = info.Size()
+			}
+		})
+	}
+
+	if err := file.ExportStemsFormat(t.TempDir(), AudioFormat(-1)); err == nil {
+		t.Error("an invalid format was accepted")
+	}
+}
+
+func TestExportStemsWAV(t *testing.T) {
+	file := testFlipnote(30)
+	dir := t.TempDir()
+
+	if err := file.ExportStems(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, track := range []string{"BGM", "SE1", "SE2"} {
+		data, err := os.ReadFile(filepath.Join(dir, file.CurrentFilename.String()+"_"+track+".wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+			t.Errorf("%s stem is not a wav file", track)
+		}
+	}
+}
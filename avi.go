@@ -0,0 +1,231 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+type AVIOptions struct {
+	RenderOptions
+	// Quality is the JPEG quality from 1 to 100, 90 when zero.
+	Quality int
+	// SampleRate of the audio stream, 32768 when zero.
+	SampleRate int
+}
+
+const (
+	aviHasIndex      = 0x10
+	aviIsInterleaved = 0x100
+	aviKeyframe      = 0x10
+)
+
+// ExportAVI writes the flipnote as an AVI file with an MJPEG video stream
+// and, if the flipnote has sound, a 16-bit PCM audio stream. Each frame is
+// followed by the audio that plays while it is shown.
+func (f *PPMFile) ExportAVI(w io.Writer, opts *AVIOptions) error {
+	if opts == nil {
+		opts = &AVIOptions{}
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	quality := opts.Quality
+	if quality == 0 {
+		quality = 90
+	}
+
+	sampleRate := opts.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 32768
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	frameCount := len(f.Frames)
+	width, height := 256*scale, 192*scale
+
+	// flipnotes without sound still carry an empty audio section
+	decoder := NewAudioDecoder(f)
+	hasAudio := decoder.hasTrack(Master)
+	var pcm []int16
+	if hasAudio {
+		pcm, err = decoder.GetAudioMasterPcm(sampleRate)
+		if err != nil {
+			return err
+		}
+	}
+
+	movi := &bytes.Buffer{}
+	movi.WriteString("movi")
+	index := &bytes.Buffer{}
+	maxChunk := 0
+
+	addChunk := func(id string, data []byte) {
+		binary.Write(index, binary.LittleEndian, []byte(id))
+		binary.Write(index, binary.LittleEndian, uint32(aviKeyframe))
+		binary.Write(index, binary.LittleEndian, uint32(movi.Len()))
+		binary.Write(index, binary.LittleEndian, uint32(len(data)))
+
+		movi.Write(riffChunk(id, data))
+		if len(data) > maxChunk {
+			maxChunk = len(data)
+		}
+	}
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	img := image.NewRGBA(renderer.Bounds())
+
+	for i := 0; i < frameCount; i++ {
+		if _, err := renderer.RenderRGBA(f.Frames[i], img); err != nil {
+			return err
+		}
+
+		frame := &bytes.Buffer{}
+		if err := jpeg.Encode(frame, img, &jpeg.Options{Quality: quality}); err != nil {
+			return err
+		}
+		addChunk("00dc", frame.Bytes())
+
+		if !hasAudio {
+			continue
+		}
+
+		start := decoder.frameOffset(i, sampleRate)
+		end := len(pcm)
+		if i+1 < frameCount {
+			end = decoder.frameOffset(i+1, sampleRate)
+		}
+		if start > len(pcm) {
+			start = len(pcm)
+		}
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+
+		samples := &bytes.Buffer{}
+		binary.Write(samples, binary.LittleEndian, pcm[start:end])
+		addChunk("01wb", samples.Bytes())
+	}
+
+	num, den := framerateFraction(f.Framerate)
+	streams := 1
+	if hasAudio {
+		streams = 2
+	}
+
+	avih := &bytes.Buffer{}
+	binary.Write(avih, binary.LittleEndian, uint32(math.Round(1e6/float64(f.Framerate))))
+	binary.Write(avih, binary.LittleEndian, uint32(float64(maxChunk)*float64(f.Framerate)+float64(sampleRate*2)))
+	binary.Write(avih, binary.LittleEndian, uint32(0))
+	binary.Write(avih, binary.LittleEndian, uint32(aviHasIndex|aviIsInterleaved))
+	binary.Write(avih, binary.LittleEndian, uint32(frameCount))
+	binary.Write(avih, binary.LittleEndian, uint32(0))
+	binary.Write(avih, binary.LittleEndian, uint32(streams))
+	binary.Write(avih, binary.LittleEndian, uint32(maxChunk))
+	binary.Write(avih, binary.LittleEndian, uint32(width))
+	binary.Write(avih, binary.LittleEndian, uint32(height))
+	binary.Write(avih, binary.LittleEndian, make([]uint32, 4))
+
+	video := &bytes.Buffer{}
+	video.WriteString("vids")
+	video.WriteString("MJPG")
+	binary.Write(video, binary.LittleEndian, uint32(0))
+	binary.Write(video, binary.LittleEndian, uint16(0))
+	binary.Write(video, binary.LittleEndian, uint16(0))
+	binary.Write(video, binary.LittleEndian, uint32(0))
+	// the stream runs at dwRate/dwScale frames per second
+	binary.Write(video, binary.LittleEndian, uint32(den))
+	binary.Write(video, binary.LittleEndian, uint32(num))
+	binary.Write(video, binary.LittleEndian, uint32(0))
+	binary.Write(video, binary.LittleEndian, uint32(frameCount))
+	binary.Write(video, binary.LittleEndian, uint32(maxChunk))
+	binary.Write(video, binary.LittleEndian, int32(-1))
+	binary.Write(video, binary.LittleEndian, uint32(0))
+	binary.Write(video, binary.LittleEndian, []int16{0, 0, int16(width), int16(height)})
+
+	bitmapInfo := &bytes.Buffer{}
+	binary.Write(bitmapInfo, binary.LittleEndian, uint32(40))
+	binary.Write(bitmapInfo, binary.LittleEndian, int32(width))
+	binary.Write(bitmapInfo, binary.LittleEndian, int32(height))
+	binary.Write(bitmapInfo, binary.LittleEndian, uint16(1))
+	binary.Write(bitmapInfo, binary.LittleEndian, uint16(24))
+	bitmapInfo.WriteString("MJPG")
+	binary.Write(bitmapInfo, binary.LittleEndian, uint32(width*height*3))
+	binary.Write(bitmapInfo, binary.LittleEndian, make([]uint32, 4))
+
+	hdrl := [][]byte{
+		riffChunk("avih", avih.Bytes()),
+		riffList("strl", riffChunk("strh", video.Bytes()), riffChunk("strf", bitmapInfo.Bytes())),
+	}
+
+	if hasAudio {
+		audio := &bytes.Buffer{}
+		audio.WriteString("auds")
+		binary.Write(audio, binary.LittleEndian, uint32(0))
+		binary.Write(audio, binary.LittleEndian, uint32(0))
+		binary.Write(audio, binary.LittleEndian, uint16(0))
+		binary.Write(audio, binary.LittleEndian, uint16(0))
+		binary.Write(audio, binary.LittleEndian, uint32(0))
+		binary.Write(audio, binary.LittleEndian, uint32(1))
+		binary.Write(audio, binary.LittleEndian, uint32(sampleRate))
+		binary.Write(audio, binary.LittleEndian, uint32(0))
+		binary.Write(audio, binary.LittleEndian, uint32(len(pcm)))
+		binary.Write(audio, binary.LittleEndian, uint32(sampleRate*2))
+		binary.Write(audio, binary.LittleEndian, int32(-1))
+		binary.Write(audio, binary.LittleEndian, uint32(2))
+		binary.Write(audio, binary.LittleEndian, []int16{0, 0, 0, 0})
+
+		waveFormat := &bytes.Buffer{}
+		binary.Write(waveFormat, binary.LittleEndian, uint16(wavFormatPcm))
+		binary.Write(waveFormat, binary.LittleEndian, uint16(1))
+		binary.Write(waveFormat, binary.LittleEndian, uint32(sampleRate))
+		binary.Write(waveFormat, binary.LittleEndian, uint32(sampleRate*2))
+		binary.Write(waveFormat, binary.LittleEndian, uint16(2))
+		binary.Write(waveFormat, binary.LittleEndian, uint16(16))
+		binary.Write(waveFormat, binary.LittleEndian, uint16(0))
+
+		hdrl = append(hdrl, riffList("strl", riffChunk("strh", audio.Bytes()), riffChunk("strf", waveFormat.Bytes())))
+	}
+
+	avi := &bytes.Buffer{}
+	avi.WriteString("AVI ")
+	avi.Write(riffList("hdrl", hdrl...))
+	avi.Write(riffChunk("LIST", movi.Bytes()))
+	avi.Write(riffChunk("idx1", index.Bytes()))
+
+	_, err = w.Write(riffChunk("RIFF", avi.Bytes()))
+	return err
+}
+
+// riffChunk returns a RIFF chunk holding data, padded to an even size.
+func riffChunk(id string, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data)+1)
+	copy(chunk, id)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+
+	if len(data)%2 != 0 {
+		chunk = append(chunk, 0)
+	}
+
+	return chunk
+}
+
+func riffList(listType string, chunks ...[]byte) []byte {
+	data := []byte(listType)
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+
+	return riffChunk("LIST", data)
+}
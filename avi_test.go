@@ -0,0 +1,37 @@
+package ppmlib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportAVIAudioStream(t *testing.T) {
+	silent := testFlipnote(12)
+	for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+		if err := silent.Audio.setTrackData(track, []byte{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		file     *PPMFile
+		hasAudio bool
+	}{
+		{"sound", testFlipnote(12), true},
+		{"silent", silent, false},
+	} {
+		out := &bytes.Buffer{}
+		if err := test.file.ExportAVI(out, nil); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if hasAudio := bytes.Contains(out.Bytes(), []byte("auds")); hasAudio != test.hasAudio {
+			t.Errorf("%s: audio stream present is %v, want %v", test.name, hasAudio, test.hasAudio)
+		}
+
+		if hasChunks := bytes.Contains(out.Bytes(), []byte("01wb")); hasChunks != test.hasAudio {
+			t.Errorf("%s: audio chunks present is %v, want %v", test.name, hasChunks, test.hasAudio)
+		}
+	}
+}
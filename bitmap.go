@@ -0,0 +1,320 @@
+package ppmlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"regexp"
+	"strconv"
+)
+
+// The formats below store the layer as 256x192 with a set bit for every
+// drawn pixel, which is black in PBM and XBM and palette index 1 in BMP.
+// The pen color is not stored, decoded layers use PenColorInverted.
+
+// EncodePBM writes the layer as a binary (P4) PBM image.
+func (l *Layer) EncodePBM(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("P4\n256 192\n")
+
+	// PBM rows start at the most significant bit
+	for _, b := range l.layerData {
+		bw.WriteByte(bits.Reverse8(b))
+	}
+
+	return bw.Flush()
+}
+
+// EncodeXBM writes the layer as an XBM image, with name prefixing its
+// definitions. XBM bits are stored in the same order as the layer's.
+func (l *Layer) EncodeXBM(w io.Writer, name string) error {
+	if !xbmName.MatchString(name) {
+		return errors.New("invalid xbm name")
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#define %s_width 256\n#define %s_height 192\n", name, name)
+	fmt.Fprintf(bw, "static unsigned char %s_bits[] = {\n", name)
+
+	for i, b := range l.layerData {
+		if i%12 == 0 {
+			bw.WriteString("  ")
+		}
+
+		fmt.Fprintf(bw, "0x%02x", b)
+
+		switch {
+		case i == len(l.layerData)-1:
+			bw.WriteString("\n")
+		case i%12 == 11:
+			bw.WriteString(",\n")
+		default:
+			bw.WriteString(", ")
+		}
+	}
+
+	bw.WriteString("};\n")
+
+	return bw.Flush()
+}
+
+// EncodeBMP writes the layer as a 1bpp BMP image with a white and a black
+// palette entry.
+func (l *Layer) EncodeBMP(w io.Writer) error {
+	const headerSize = 14 + 40 + 2*4
+
+	header := make([]byte, headerSize)
+	copy(header, "BM")
+	binary.LittleEndian.PutUint32(header[2:], uint32(headerSize+len(l.layerData)))
+	binary.LittleEndian.PutUint32(header[10:], headerSize)
+
+	binary.LittleEndian.PutUint32(header[14:], 40)
+	binary.LittleEndian.PutUint32(header[18:], 256)
+	binary.LittleEndian.PutUint32(header[22:], 192)
+	binary.LittleEndian.PutUint16(header[26:], 1)
+	binary.LittleEndian.PutUint16(header[28:], 1)
+	binary.LittleEndian.PutUint32(header[34:], uint32(len(l.layerData)))
+	binary.LittleEndian.PutUint32(header[46:], 2)
+
+	// palette entries are BGRX, index 0 white and index 1 black
+	copy(header[54:], []byte{0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00})
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+
+	// rows are stored bottom up, starting at the most significant bit, and
+	// 32 bytes wide so they need no padding
+	for y := 191; y >= 0; y-- {
+		for _, b := range l.layerData[y*32 : (y+1)*32] {
+			bw.WriteByte(bits.Reverse8(b))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// DecodePBM reads a 256x192 binary (P4) or plain (P1) PBM image into a new
+// layer.
+func DecodePBM(r io.Reader) (*Layer, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := pbmToken(br)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P4" && magic != "P1" {
+		return nil, errors.New("not a pbm image")
+	}
+
+	size := make([]int, 2)
+	for i := range size {
+		token, err := pbmToken(br)
+		if err != nil {
+			return nil, err
+		}
+
+		size[i], err = strconv.Atoi(token)
+		if err != nil {
+			return nil, errors.New("invalid pbm size")
+		}
+	}
+
+	if size[0] != 256 || size[1] != 192 {
+		return nil, errors.New("pbm image is not 256x192")
+	}
+
+	layer := newBitmapLayer()
+
+	if magic == "P4" {
+		// pbmToken consumed the single whitespace before the raster
+		if _, err := io.ReadFull(br, layer.layerData); err != nil {
+			return nil, err
+		}
+
+		for i, b := range layer.layerData {
+			layer.layerData[i] = bits.Reverse8(b)
+		}
+
+		return layer, nil
+	}
+
+	// plain pixels may or may not be separated by whitespace
+	for p := 0; p < 256*192; {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case c == '0' || c == '1':
+			layer.Set(p%256, p/256, c == '1')
+			p++
+		case c == '#':
+			if _, err := br.ReadString('\n'); err != nil {
+				return nil, err
+			}
+		case !isPBMSpace(c):
+			return nil, errors.New("invalid pbm pixel")
+		}
+	}
+
+	return layer, nil
+}
+
+func newBitmapLayer() *Layer {
+	layer := NewLayer()
+	layer.PenColor = PenColorInverted
+
+	return layer
+}
+
+// pbmToken reads a whitespace separated header token, skipping comments. It
+// consumes the whitespace byte following the token.
+func pbmToken(br *bufio.Reader) (string, error) {
+	token := make([]byte, 0)
+
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			return "", err
+		}
+
+		switch {
+		case c == '#':
+			if _, err := br.ReadString('\n'); err != nil {
+				return "", err
+			}
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		case isPBMSpace(c):
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, c)
+		}
+	}
+}
+
+func isPBMSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+var (
+	xbmName   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	xbmDefine = regexp.MustCompile(`#define\s+\w*?_?(width|height)\s+(\d+)`)
+	xbmBits   = regexp.MustCompile(`(?s)\{(.*?)\}`)
+	xbmByte   = regexp.MustCompile(`0[xX]([0-9a-fA-F]{1,2})\b`)
+)
+
+// DecodeXBM reads a 256x192 XBM image into a new layer.
+func DecodeXBM(r io.Reader) (*Layer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	size := make(map[string]string)
+	for _, match := range xbmDefine.FindAllSubmatch(data, -1) {
+		size[string(match[1])] = string(match[2])
+	}
+
+	if size["width"] != "256" || size["height"] != "192" {
+		return nil, errors.New("xbm image is not 256x192")
+	}
+
+	body := xbmBits.FindSubmatch(data)
+	if body == nil {
+		return nil, errors.New("xbm image has no bits")
+	}
+
+	values := xbmByte.FindAllSubmatch(body[1], -1)
+	if len(values) != 32*192 {
+		return nil, errors.New("invalid xbm data length")
+	}
+
+	layer := newBitmapLayer()
+	for i, value := range values {
+		b, _ := strconv.ParseUint(string(value[1]), 16, 8)
+		layer.layerData[i] = byte(b)
+	}
+
+	return layer, nil
+}
+
+// DecodeBMP reads a 256x192 uncompressed 1bpp BMP image into a new layer.
+// Pixels of the darker palette entry are drawn.
+func DecodeBMP(r io.Reader) (*Layer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 14+40 || !bytes.HasPrefix(data, []byte("BM")) {
+		return nil, errors.New("not a bmp image")
+	}
+
+	offset := int(binary.LittleEndian.Uint32(data[10:]))
+	dibSize := int(binary.LittleEndian.Uint32(data[14:]))
+	width := int32(binary.LittleEndian.Uint32(data[18:]))
+	height := int32(binary.LittleEndian.Uint32(data[22:]))
+	bpp := binary.LittleEndian.Uint16(data[28:])
+	compression := binary.LittleEndian.Uint32(data[30:])
+
+	if dibSize < 40 {
+		return nil, errors.New("unsupported bmp header")
+	}
+
+	if bpp != 1 || compression != 0 {
+		return nil, errors.New("bmp image is not uncompressed 1bpp")
+	}
+
+	// a negative height means the rows are stored top down
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+
+	if width != 256 || height != 192 {
+		return nil, errors.New("bmp image is not 256x192")
+	}
+
+	palette := 14 + dibSize
+	if palette+8 > len(data) || offset+32*192 > len(data) {
+		return nil, errors.New("bmp image is truncated")
+	}
+
+	luma := func(entry []byte) int {
+		return 114*int(entry[0]) + 587*int(entry[1]) + 299*int(entry[2])
+	}
+
+	// the darker entry is the drawn one
+	invert := luma(data[palette+4:]) > luma(data[palette:])
+
+	layer := newBitmapLayer()
+	for y := 0; y < 192; y++ {
+		row := 191 - y
+		if topDown {
+			row = y
+		}
+
+		src := data[offset+row*32 : offset+(row+1)*32]
+		for i, b := range src {
+			if invert {
+				b = ^b
+			}
+
+			layer.layerData[y*32+i] = bits.Reverse8(b)
+		}
+	}
+
+	return layer, nil
+}
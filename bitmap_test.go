@@ -0,0 +1,122 @@
+package ppmlib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testLayer() *Layer {
+	layer := NewLayer()
+	layer.PenColor = PenColorRed
+
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			layer.Set(x, y, (x*7+y*3)%5 == 0 || x == y)
+		}
+	}
+
+	return layer
+}
+
+func TestBitmapRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(*Layer, io.Writer) error
+		decode func(io.Reader) (*Layer, error)
+	}{
+		{"pbm", (*Layer).EncodePBM, DecodePBM},
+		{"xbm", func(l *Layer, w io.Writer) error { return l.EncodeXBM(w, "layer") }, DecodeXBM},
+		{"bmp", (*Layer).EncodeBMP, DecodeBMP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layer := testLayer()
+
+			buf := &bytes.Buffer{}
+			if err := test.encode(layer, buf); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := test.decode(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(decoded.layerData, layer.layerData) {
+				t.Fatal("decoded layer differs from the encoded one")
+			}
+
+			if decoded.PenColor != PenColorInverted {
+				t.Fatalf("got pen color %v, want %v", decoded.PenColor, PenColorInverted)
+			}
+		})
+	}
+}
+
+func TestDecodePlainPBM(t *testing.T) {
+	layer := testLayer()
+
+	plain := &strings.Builder{}
+	plain.WriteString("P1\n# plain\n256 192\n")
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			if layer.Get(x, y) {
+				plain.WriteByte('1')
+			} else {
+				plain.WriteByte('0')
+			}
+		}
+		plain.WriteByte('\n')
+	}
+
+	decoded, err := DecodePBM(strings.NewReader(plain.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded.layerData, layer.layerData) {
+		t.Fatal("decoded layer differs from the encoded one")
+	}
+}
+
+func TestDecodeBMPInvertedPalette(t *testing.T) {
+	layer := testLayer()
+
+	buf := &bytes.Buffer{}
+	if err := layer.EncodeBMP(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// swap the palette entries and invert the pixels, the drawn pixels stay
+	// the black ones
+	data := buf.Bytes()
+	copy(data[54:], []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0x00})
+	for i := 62; i < len(data); i++ {
+		data[i] = ^data[i]
+	}
+
+	decoded, err := DecodeBMP(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded.layerData, layer.layerData) {
+		t.Fatal("decoded layer differs from the encoded one")
+	}
+}
+
+func TestDecodeBitmapSize(t *testing.T) {
+	pbm := fmt.Sprintf("P4\n128 96\n%s", make([]byte, 16*96))
+	if _, err := DecodePBM(strings.NewReader(pbm)); err == nil {
+		t.Fatal("expected an error for a pbm image that is not 256x192")
+	}
+
+	xbm := "#define layer_width 128\n#define layer_height 96\nstatic unsigned char layer_bits[] = { 0x00 };\n"
+	if _, err := DecodeXBM(strings.NewReader(xbm)); err == nil {
+		t.Fatal("expected an error for an xbm image that is not 256x192")
+	}
+}
@@ -71,6 +71,9 @@ const (
 	SE1
 	SE2
 	SE3
+	// Master is the mix of all tracks. Anything that decodes audio accepts
+	// it, functions that work on the raw ADPCM of a single track reject it.
+	Master
 )
 
 func (p PPMAudioTrack) String() string {
@@ -83,6 +86,146 @@ func (p PPMAudioTrack) String() string {
 		return "SE2"
 	case SE3:
 		return "SE3"
+	case Master:
+		return "Master"
+	}
+
+	return "Unknown"
+}
+
+type AudioFormat int
+
+const (
+	// AudioFormatWAV is 16-bit signed PCM in a wav container.
+	AudioFormatWAV AudioFormat = iota
+	// AudioFormatWAVFloat32 is 32-bit IEEE float PCM in a wav container.
+	AudioFormatWAVFloat32
+	// AudioFormatPCM is headerless 16-bit signed little endian PCM.
+	AudioFormatPCM
+	AudioFormatAIFF
+	AudioFormatFLAC
+)
+
+func (a AudioFormat) String() string {
+	switch a {
+	case AudioFormatWAV:
+		return "WAV"
+	case AudioFormatWAVFloat32:
+		return "WAVFloat32"
+	case AudioFormatPCM:
+		return "PCM"
+	case AudioFormatAIFF:
+		return "AIFF"
+	case AudioFormatFLAC:
+		return "FLAC"
+	}
+
+	return "Unknown"
+}
+
+func (a AudioFormat) extension() string {
+	switch a {
+	case AudioFormatWAV, AudioFormatWAVFloat32:
+		return "wav"
+	case AudioFormatPCM:
+		return "pcm"
+	case AudioFormatAIFF:
+		return "aiff"
+	case AudioFormatFLAC:
+		return "flac"
+	}
+
+	return ""
+}
+
+type VideoPreset int
+
+const (
+	// VideoPresetH264 is H.264 video and AAC audio in a fragmented MP4.
+	VideoPresetH264 VideoPreset = iota
+	// VideoPresetVP9 is VP9 video and Opus audio in WebM.
+	VideoPresetVP9
+	// VideoPresetFFV1 is lossless FFV1 video and FLAC audio in Matroska.
+	VideoPresetFFV1
+)
+
+func (v VideoPreset) String() string {
+	switch v {
+	case VideoPresetH264:
+		return "H264"
+	case VideoPresetVP9:
+		return "VP9"
+	case VideoPresetFFV1:
+		return "FFV1"
+	}
+
+	return "Unknown"
+}
+
+type ImageFormat int
+
+const (
+	ImageFormatPNG ImageFormat = iota
+	ImageFormatJPEG
+)
+
+func (i ImageFormat) String() string {
+	switch i {
+	case ImageFormatPNG:
+		return "PNG"
+	case ImageFormatJPEG:
+		return "JPEG"
+	}
+
+	return "Unknown"
+}
+
+type ContactSheetSource int
+
+const (
+	ContactSheetThumbnail ContactSheetSource = iota
+	ContactSheetFirstFrame
+	ContactSheetMiddleFrame
+	ContactSheetLastFrame
+	// ContactSheetFirstMiddleLast shows the first, middle and last frame
+	// side by side.
+	ContactSheetFirstMiddleLast
+)
+
+func (c ContactSheetSource) String() string {
+	switch c {
+	case ContactSheetThumbnail:
+		return "Thumbnail"
+	case ContactSheetFirstFrame:
+		return "FirstFrame"
+	case ContactSheetMiddleFrame:
+		return "MiddleFrame"
+	case ContactSheetLastFrame:
+		return "LastFrame"
+	case ContactSheetFirstMiddleLast:
+		return "FirstMiddleLast"
+	}
+
+	return "Unknown"
+}
+
+type NPYLayout int
+
+const (
+	// NPYLayoutLayers stores both layers separately as 0 or 1, in an array
+	// of shape (frames, 2, 192, 256).
+	NPYLayoutLayers NPYLayout = iota
+	// NPYLayoutMerged stores one array of shape (frames, 192, 256) holding
+	// 0 for paper, 1 for layer 1 and 2 for layer 2. Layer 1 is on top.
+	NPYLayoutMerged
+)
+
+func (n NPYLayout) String() string {
+	switch n {
+	case NPYLayoutLayers:
+		return "Layers"
+	case NPYLayoutMerged:
+		return "Merged"
 	}
 
 	return "Unknown"
@@ -0,0 +1,223 @@
+package ppmlib
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type ContactSheetOptions struct {
+	Source ContactSheetSource
+	// Columns of the grid, 6 when zero.
+	Columns int
+	// Scale is an integer upscale factor for the images, 1 when zero.
+	Scale int
+	// Palette is PaletteDefault when nil. Thumbnails keep their own colors.
+	Palette *Palette
+	// Padding around and between cells in pixels, 8 when zero.
+	Padding int
+	// Background and Text colors, light gray and black when nil.
+	Background *color.RGBA
+	Text       *color.RGBA
+}
+
+var (
+	contactSheetBackground = color.RGBA{0xE8, 0xE8, 0xE8, 0xFF}
+	contactSheetText       = color.RGBA{0x00, 0x00, 0x00, 0xFF}
+)
+
+// RenderContactSheet lays out an image of each flipnote in a grid, labeled
+// with its current filename, current author and timestamp. Labels use a
+// fixed ASCII font, other characters, such as the Japanese of many author
+// names, are drawn as a replacement glyph.
+func RenderContactSheet(files []*PPMFile, opts *ContactSheetOptions) (image.Image, error) {
+	if opts == nil {
+		opts = &ContactSheetOptions{}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no flipnotes to lay out")
+	}
+
+	if opts.Columns < 0 {
+		return nil, errors.New("invalid column count")
+	}
+
+	if opts.Padding < 0 {
+		return nil, errors.New("invalid padding")
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := opts.Columns
+	if columns == 0 {
+		columns = 6
+	}
+	if columns > len(files) {
+		columns = len(files)
+	}
+
+	padding := opts.Padding
+	if padding == 0 {
+		padding = 8
+	}
+
+	background, text := opts.Background, opts.Text
+	if background == nil {
+		background = &contactSheetBackground
+	}
+	if text == nil {
+		text = &contactSheetText
+	}
+
+	face := basicfont.Face7x13
+	lineHeight := face.Metrics().Height.Ceil()
+
+	labels := make([][]string, len(files))
+	labelWidth := 0
+	for i, file := range files {
+		labels[i] = contactSheetLabels(file)
+
+		for _, label := range labels[i] {
+			if w := font.MeasureString(face, label).Ceil(); w > labelWidth {
+				labelWidth = w
+			}
+		}
+	}
+
+	images := make([][]image.Image, len(files))
+	imageSize := image.Point{}
+	for i, file := range files {
+		images[i], err = contactSheetImages(file, opts.Source, scale, opts.Palette)
+		if err != nil {
+			return nil, err
+		}
+
+		width, height := 0, 0
+		for j, img := range images[i] {
+			if j > 0 {
+				width += padding
+			}
+			width += img.Bounds().Dx()
+			if img.Bounds().Dy() > height {
+				height = img.Bounds().Dy()
+			}
+		}
+
+		if width > imageSize.X {
+			imageSize.X = width
+		}
+		if height > imageSize.Y {
+			imageSize.Y = height
+		}
+	}
+
+	cellWidth := imageSize.X
+	if labelWidth > cellWidth {
+		cellWidth = labelWidth
+	}
+	cellHeight := imageSize.Y + padding/2 + 3*lineHeight
+
+	rows := (len(files) + columns - 1) / columns
+	sheet := image.NewRGBA(image.Rect(0, 0,
+		padding+columns*(cellWidth+padding),
+		padding+rows*(cellHeight+padding),
+	))
+	draw.Draw(sheet, sheet.Rect, image.NewUniform(*background), image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{Dst: sheet, Src: image.NewUniform(*text), Face: face}
+
+	for i := range files {
+		x := padding + (i%columns)*(cellWidth+padding)
+		y := padding + (i/columns)*(cellHeight+padding)
+
+		imageX := x
+		for _, img := range images[i] {
+			r := image.Rect(imageX, y, imageX+img.Bounds().Dx(), y+img.Bounds().Dy())
+			draw.Draw(sheet, r, img, img.Bounds().Min, draw.Src)
+			imageX += img.Bounds().Dx() + padding
+		}
+
+		baseline := y + imageSize.Y + padding/2 + face.Metrics().Ascent.Ceil()
+		for j, label := range labels[i] {
+			drawer.Dot = fixed.P(x, baseline+j*lineHeight)
+			drawer.DrawString(label)
+		}
+	}
+
+	return sheet, nil
+}
+
+func contactSheetLabels(file *PPMFile) []string {
+	labels := []string{"", "", ""}
+
+	if file.CurrentFilename != nil {
+		labels[0] = file.CurrentFilename.String()
+	}
+	if file.CurrentAuthor != nil {
+		labels[1] = strings.TrimRight(file.CurrentAuthor.Name, "\x00")
+	}
+	if file.Timestamp != nil {
+		labels[2] = file.Timestamp.String()
+	}
+
+	return labels
+}
+
+// contactSheetImages returns the images shown for a flipnote. Absent
+// thumbnails and frames are left out.
+func contactSheetImages(file *PPMFile, source ContactSheetSource, scale int, palette *Palette) ([]image.Image, error) {
+	if source == ContactSheetThumbnail {
+		if len(file.Thumbnail) == 0 {
+			return nil, nil
+		}
+
+		thumbnail, err := (&Renderer{Scale: scale}).RenderThumbnail(file)
+		if err != nil {
+			return nil, err
+		}
+
+		return []image.Image{thumbnail}, nil
+	}
+
+	count := len(file.Frames)
+
+	var indices []int
+	switch source {
+	case ContactSheetFirstFrame:
+		indices = []int{0}
+	case ContactSheetMiddleFrame:
+		indices = []int{count / 2}
+	case ContactSheetLastFrame:
+		indices = []int{count - 1}
+	case ContactSheetFirstMiddleLast:
+		indices = []int{0, count / 2, count - 1}
+	default:
+		return nil, errors.New("invalid contact sheet source")
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	renderer := file.renderer(scale, palette, nil)
+	images := make([]image.Image, 0, len(indices))
+	for _, index := range indices {
+		img, err := renderer.RenderPaletted(file.Frames[index], nil)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	return images, nil
+}
@@ -0,0 +1,92 @@
+package ppmlib
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRenderThumbnailScale(t *testing.T) {
+	file := testFlipnote(1)
+	file.Thumbnail = make([]byte, 1536)
+	for i := range file.Thumbnail {
+		file.Thumbnail[i] = byte(i*7 + i>>5)
+	}
+
+	want, err := file.ThumbnailImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := (&Renderer{Scale: 3}).RenderThumbnail(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Rect != image.Rect(0, 0, 192, 144) {
+		t.Fatalf("got bounds %v", img.Rect)
+	}
+
+	for y := 0; y < 144; y++ {
+		for x := 0; x < 192; x++ {
+			if got, want := img.ColorIndexAt(x, y), want.ColorIndexAt(x/3, y/3); got != want {
+				t.Fatalf("pixel %d,%d: got index %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRenderContactSheetErrors(t *testing.T) {
+	files := []*PPMFile{testFlipnote(3)}
+
+	tests := []struct {
+		name  string
+		files []*PPMFile
+		opts  *ContactSheetOptions
+	}{
+		{"no files", nil, nil},
+		{"unknown source", files, &ContactSheetOptions{Source: ContactSheetSource(-1)}},
+		{"negative columns", files, &ContactSheetOptions{Columns: -1}},
+		{"negative padding", files, &ContactSheetOptions{Padding: -1}},
+		{"negative scale", files, &ContactSheetOptions{Scale: -1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := RenderContactSheet(test.files, test.opts); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestRenderContactSheetFrames(t *testing.T) {
+	files := []*PPMFile{testFlipnote(3), testFlipnote(5)}
+
+	img, err := RenderContactSheet(files, &ContactSheetOptions{Source: ContactSheetFirstMiddleLast})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two cells side by side, each holding three frames
+	if got := img.Bounds().Dx(); got != 8+2*(3*256+2*8+8) {
+		t.Fatalf("got width %d", got)
+	}
+}
+
+func TestRenderContactSheetThumbnails(t *testing.T) {
+	withThumbnail := testFlipnote(3)
+	withThumbnail.Thumbnail = make([]byte, 1536)
+	withoutThumbnail := testFlipnote(3)
+	withoutThumbnail.Thumbnail = nil
+
+	opts := &ContactSheetOptions{Source: ContactSheetThumbnail, Scale: 2}
+	if _, err := RenderContactSheet([]*PPMFile{withThumbnail, withoutThumbnail}, opts); err != nil {
+		t.Fatalf("an absent thumbnail is left out, got %v", err)
+	}
+
+	corrupt := testFlipnote(3)
+	corrupt.Thumbnail = make([]byte, 100)
+	if _, err := RenderContactSheet([]*PPMFile{withThumbnail, corrupt}, opts); err == nil {
+		t.Error("a corrupt thumbnail was left out silently")
+	}
+}
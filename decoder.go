@@ -8,8 +8,7 @@ import (
 )
 
 type AdpcmDecoder struct {
-	stepIndex int
-	flipnote  *PPMFile
+	flipnote *PPMFile
 }
 
 func NewAudioDecoder(flipnote *PPMFile) *AdpcmDecoder {
@@ -36,93 +35,136 @@ var stepTable = []int{
 }
 
 func (d *AdpcmDecoder) GetAudioMasterPcm(dstFreq int) ([]int16, error) {
-	var err error
-
-	duration := getTime(float32(d.flipnote.FrameCount), float32(d.flipnote.Framerate))
+	master := make([]int16, d.timelineSize(dstFreq))
 
-	dstSize := int(duration*float32(dstFreq)) + 1
-	master := make([]int16, dstSize+1)
-
-	hasBgm := d.flipnote.Audio.Header.BGMTrackSize > 0
-	hasSe1 := d.flipnote.Audio.Header.SE1TrackSize > 0
-	hasSe2 := d.flipnote.Audio.Header.SE2TrackSize > 0
-	hasSe3 := d.flipnote.Audio.Header.SE3TrackSize > 0
+	for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+		if !d.hasTrack(track) {
+			continue
+		}
 
-	if hasBgm {
-		bgmPcm, err := d.getAudioTrackPcm(dstFreq, BGM)
+		pcm, err := d.getAudioTrackPcm(dstFreq, track)
 		if err != nil {
 			return nil, err
 		}
-		master = pcmAudioMix(bgmPcm, master, 0)
+		master = d.mixTrack(pcm, master, dstFreq, track)
 	}
 
-	if hasSe1 || hasSe2 || hasSe3 {
-		samplesPerFrame := float32(dstFreq) / d.flipnote.Framerate
-		var se1Pcm []int16
-		var se2Pcm []int16
-		var se3Pcm []int16
+	return master, nil
+}
 
-		if hasSe1 {
-			se1Pcm, err = d.getAudioTrackPcm(dstFreq, SE1)
-			if err != nil {
-				return nil, err
-			}
-		}
+// GetAudioStemPcm returns a single track on its own, as it is mixed into
+// GetAudioMasterPcm. Every stem spans the whole timeline, sound effects are
+// placed on every frame that triggers them, so the stems line up with each
+// other and add up to the master. The Master track returns the master mix.
+func (d *AdpcmDecoder) GetAudioStemPcm(dstFreq int, track PPMAudioTrack) ([]int16, error) {
+	switch track {
+	case Master:
+		return d.GetAudioMasterPcm(dstFreq)
+	case BGM, SE1, SE2, SE3:
+	default:
+		return nil, errors.New("invalid track")
+	}
 
-		if hasSe2 {
-			se2Pcm, err = d.getAudioTrackPcm(dstFreq, SE2)
-			if err != nil {
-				return nil, err
-			}
-		}
+	stem := make([]int16, d.timelineSize(dstFreq))
+	if !d.hasTrack(track) {
+		return stem, nil
+	}
 
-		if hasSe3 {
-			se3Pcm, err = d.getAudioTrackPcm(dstFreq, SE3)
-			if err != nil {
-				return nil, err
-			}
-		}
+	pcm, err := d.getAudioTrackPcm(dstFreq, track)
+	if err != nil {
+		return nil, err
+	}
 
-		seFlags := d.flipnote.SoundEffectFlags
+	return d.mixTrack(pcm, stem, dstFreq, track), nil
+}
 
-		for i := 0; i < int(d.flipnote.FrameCount); i++ {
-			seOffset := int(math.Ceil(float64(i) * float64(samplesPerFrame)))
-			flag := seFlags[i]
+func (d *AdpcmDecoder) timelineSize(dstFreq int) int {
+	duration := getTime(float32(d.flipnote.FrameCount), float32(d.flipnote.Framerate))
 
-			if hasSe1 && flag == 1 {
-				master = pcmAudioMix(se1Pcm, master, seOffset)
-			}
+	return int(duration*float32(dstFreq)) + 2
+}
 
-			if hasSe2 && flag == 2 {
-				master = pcmAudioMix(se2Pcm, master, seOffset)
-			}
+func (d *AdpcmDecoder) hasTrack(track PPMAudioTrack) bool {
+	if d.flipnote.Audio == nil {
+		return false
+	}
+
+	header := d.flipnote.Audio.Header
+
+	switch track {
+	case BGM:
+		return header.BGMTrackSize > 0
+	case SE1:
+		return header.SE1TrackSize > 0
+	case SE2:
+		return header.SE2TrackSize > 0
+	case SE3:
+		return header.SE3TrackSize > 0
+	case Master:
+		return header.BGMTrackSize > 0 || header.SE1TrackSize > 0 || header.SE2TrackSize > 0 || header.SE3TrackSize > 0
+	}
+
+	return false
+}
 
-			if hasSe3 && flag == 4 {
-				master = pcmAudioMix(se3Pcm, master, seOffset)
-			}
+// mixTrack mixes pcm into dst. BGM starts at the first frame, sound effects
+// are mixed in at every frame whose flag has the track's bit set.
+func (d *AdpcmDecoder) mixTrack(pcm []int16, dst []int16, dstFreq int, track PPMAudioTrack) []int16 {
+	if track == BGM {
+		return pcmAudioMix(pcm, dst, 0)
+	}
+
+	for i := 0; i < int(d.flipnote.FrameCount); i++ {
+		if d.triggers(i, track) {
+			dst = pcmAudioMix(pcm, dst, d.frameOffset(i, dstFreq))
 		}
 	}
 
-	return master, nil
+	return dst
 }
 
-func (d *AdpcmDecoder) getAudioTrackPcm(dstFreq int, track PPMAudioTrack) ([]int16, error) {
-	srcPcm, err := d.decode(track)
-	if err != nil {
-		return nil, err
+// triggers reports whether the sound effect track is played on frame index.
+func (d *AdpcmDecoder) triggers(index int, track PPMAudioTrack) bool {
+	seFlags := d.flipnote.SoundEffectFlags
+	if track < SE1 || track > SE3 || index >= len(seFlags) {
+		return false
 	}
 
-	speed := d.flipnote.BGMRate
-	framerate := d.flipnote.Framerate
+	return seFlags[index]&(byte(1)<<(track-SE1)) != 0
+}
+
+// frameOffset returns the sample at which frame index starts.
+func (d *AdpcmDecoder) frameOffset(index int, dstFreq int) int {
+	samplesPerFrame := float32(dstFreq) / d.flipnote.Framerate
+
+	return int(math.Ceil(float64(index) * float64(samplesPerFrame)))
+}
 
+// trackFrequency returns the rate a track is played back at. BGM is sped up
+// or slowed down when the framerate was changed after recording it.
+func (d *AdpcmDecoder) trackFrequency(track PPMAudioTrack) int {
 	srcFreq := 8192
 
 	if track == BGM {
+		speed := d.flipnote.BGMRate
+		framerate := d.flipnote.Framerate
+
 		bgmAdjust := (1.0 / speed) / (1.0 / framerate)
 		srcFreq = int(float32(srcFreq) * bgmAdjust)
 	}
 
-	if int(srcFreq) != dstFreq {
+	return srcFreq
+}
+
+func (d *AdpcmDecoder) getAudioTrackPcm(dstFreq int, track PPMAudioTrack) ([]int16, error) {
+	srcPcm, err := d.decode(track)
+	if err != nil {
+		return nil, err
+	}
+
+	srcFreq := d.trackFrequency(track)
+
+	if srcFreq != dstFreq {
 		return pcmResampleNearestNeighbour(srcPcm, srcFreq, dstFreq)
 	}
 
@@ -131,8 +173,7 @@ func (d *AdpcmDecoder) getAudioTrackPcm(dstFreq int, track PPMAudioTrack) ([]int
 
 func pcmResampleNearestNeighbour(src []int16, srcFreq int, dstFreq int) ([]int16, error) {
 	srcLen := len(src)
-	srcDuration := srcLen / int(srcFreq)
-	dstLen := srcDuration * dstFreq
+	dstLen := int(int64(srcLen) * int64(dstFreq) / int64(srcFreq))
 	dst := make([]int16, int(dstLen))
 	adjFreq := float32(srcFreq) / float32(dstFreq)
 
@@ -164,72 +205,61 @@ func pcmAudioMix(src []int16, dst []int16, offset int) []int16 {
 		if offset+i >= len(dst) || i >= len(src) {
 			break
 		}
-		samp := dst[offset+i] + (src[i] / 2)
-		dst[offset+i] = utils.Clamp(samp, -32768, 32767)
+		samp := int(dst[offset+i]) + int(src[i]/2)
+		dst[offset+i] = int16(utils.Clamp(samp, -32768, 32767))
 	}
 
 	return dst
 }
 
 func (d *AdpcmDecoder) decode(track PPMAudioTrack) ([]int16, error) {
-	data := d.flipnote.Audio.Data
-
-	var src []byte
-	switch track {
-	case BGM:
-		src = data.RawBGM
-	case SE1:
-		src = data.RawSE1
-	case SE2:
-		src = data.RawSE2
-	case SE3:
-		src = data.RawSE3
-	default:
-		return nil, errors.New("invalid track")
+	src, err := d.flipnote.Audio.Data.trackData(track)
+	if err != nil {
+		return nil, err
 	}
 
-	srcSize := len(src)
-	dst := make([]int16, srcSize*2)
+	dst := make([]int16, 0, len(src)*2)
+	state := &adpcmState{}
 
-	var srcPtr, dstPtr, sample, predictor int
-	d.stepIndex = 0
-	lowNibble := true
+	for _, b := range src {
+		dst = append(dst, state.next(int(b&0xF)))
+		dst = append(dst, state.next(int(b>>4)))
+	}
 
-	for srcPtr < srcSize {
-		sample = int(src[srcPtr] & 0xF)
+	return dst, nil
+}
 
-		if !lowNibble {
-			sample = int(src[srcPtr] >> 4)
-			srcPtr++
-		}
-		lowNibble = !lowNibble
+// adpcmState holds the predictor and step index of an IMA ADPCM stream.
+// Flipnote streams start at zero for both and store the low nibble first.
+type adpcmState struct {
+	predictor int
+	stepIndex int
+}
 
-		step := stepTable[d.stepIndex]
-		diff := step >> 3
+func (s *adpcmState) next(sample int) int16 {
+	step := stepTable[s.stepIndex]
+	diff := step >> 3
 
-		if (sample & 1) != 0 {
-			diff += step >> 2
-		}
-		if (sample & 2) != 0 {
-			diff += step >> 1
-		}
-		if (sample & 4) != 0 {
-			diff += step
-		}
-		if (sample & 8) != 0 {
-			diff = -diff
-		}
+	if (sample & 1) != 0 {
+		diff += step >> 2
+	}
+	if (sample & 2) != 0 {
+		diff += step >> 1
+	}
+	if (sample & 4) != 0 {
+		diff += step
+	}
+	if (sample & 8) != 0 {
+		diff = -diff
+	}
 
-		predictor += diff
-		predictor = utils.Clamp(predictor, -32768, 32767)
+	s.predictor += diff
+	s.predictor = utils.Clamp(s.predictor, -32768, 32767)
 
-		d.stepIndex += indexTable[sample]
-		d.stepIndex = utils.Clamp(d.stepIndex, 0, 88)
-		dst[dstPtr] = int16(predictor)
-		dstPtr++
-	}
+	s.stepIndex += indexTable[sample]
+	s.stepIndex = utils.Clamp(s.stepIndex, 0, 88)
 
-	return dst, nil
+	return int16(s.predictor)
 }
 
 func getTime(fc, fr float32) float32 {
@@ -0,0 +1,189 @@
+package ppmlib
+
+import (
+	"bytes"
+	"testing"
+)
+
+// soundEffectFlipnote returns a one second, one frame flipnote with a short
+// sound on track, played with the frame's sound effect flag set to flag.
+func soundEffectFlipnote(track PPMAudioTrack, flag byte) *PPMFile {
+	in := make([]int, 1024)
+	for i := range in {
+		in[i] = 4000
+		if i%16 < 8 {
+			in[i] = -4000
+		}
+	}
+
+	sound := make([]byte, 0)
+	Encode(in, &sound)
+
+	audio := NewAudio()
+	switch track {
+	case SE1:
+		audio.Data.RawSE1 = sound
+		audio.Header.SE1TrackSize = uint32(len(sound))
+	case SE2:
+		audio.Data.RawSE2 = sound
+		audio.Header.SE2TrackSize = uint32(len(sound))
+	case SE3:
+		audio.Data.RawSE3 = sound
+		audio.Header.SE3TrackSize = uint32(len(sound))
+	}
+
+	return &PPMFile{
+		FrameCount:       1,
+		Framerate:        1,
+		SoundEffectFlags: []byte{flag},
+		Audio:            audio,
+	}
+}
+
+func silent(pcm []int16) bool {
+	for _, sample := range pcm {
+		if sample != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestMasterMixesEverySoundEffectOfAFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		track PPMAudioTrack
+		flag  byte
+		heard bool
+	}{
+		{"SE1 alone", SE1, 0x1, true},
+		{"SE1 with SE2", SE1, 0x3, true},
+		{"SE2 with SE1 and SE3", SE2, 0x7, true},
+		{"SE3 with SE1", SE3, 0x5, true},
+		{"SE2 not triggered", SE2, 0x5, false},
+		{"no flags", SE1, 0x0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pcm, err := NewAudioDecoder(soundEffectFlipnote(test.track, test.flag)).GetAudioMasterPcm(8192)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if heard := !silent(pcm); heard != test.heard {
+				t.Errorf("%v heard = %v, want %v", test.track, heard, test.heard)
+			}
+		})
+	}
+}
+
+func TestResampleKeepsPartialSeconds(t *testing.T) {
+	tests := []struct {
+		srcLen, srcFreq, dstFreq int
+		want                     int
+	}{
+		{4096, 8192, 32768, 16384},
+		{100, 8192, 16384, 200},
+		{12288, 8192, 44100, 66150},
+		{8192, 8192, 8000, 8000},
+	}
+
+	for _, test := range tests {
+		dst, err := pcmResampleNearestNeighbour(make([]int16, test.srcLen), test.srcFreq, test.dstFreq)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(dst) != test.want {
+			t.Errorf("resampling %d samples from %d to %d Hz gave %d samples, want %d", test.srcLen, test.srcFreq, test.dstFreq, len(dst), test.want)
+		}
+	}
+}
+
+func TestMixClampsInsteadOfWrapping(t *testing.T) {
+	tests := []struct {
+		dst, src, want int16
+	}{
+		{30000, 10000, 32767},
+		{-30000, -10000, -32768},
+		{32767, 32767, 32767},
+		{1000, 2000, 2000},
+		{-1000, 2000, 0},
+	}
+
+	for _, test := range tests {
+		if got := pcmAudioMix([]int16{test.src}, []int16{test.dst}, 0)[0]; got != test.want {
+			t.Errorf("mixing %d into %d gave %d, want %d", test.src, test.dst, got, test.want)
+		}
+	}
+}
+
+func TestStemsAddUpToMaster(t *testing.T) {
+	decoder := NewAudioDecoder(testFlipnote(30))
+
+	for _, rate := range []int{8192, 32768, 44100} {
+		master, err := decoder.GetAudioMasterPcm(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := make([]int, len(master))
+		for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+			stem, err := decoder.GetAudioStemPcm(rate, track)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(stem) != len(master) {
+				t.Fatalf("%v stem at %d Hz has %d samples, want %d", track, rate, len(stem), len(master))
+			}
+
+			for i, sample := range stem {
+				sum[i] += int(sample)
+			}
+		}
+
+		for i := range master {
+			if sum[i] != int(master[i]) {
+				t.Fatalf("stems at %d Hz add up to %d at sample %d, master is %d", rate, sum[i], i, master[i])
+			}
+		}
+	}
+}
+
+func TestMasterTrack(t *testing.T) {
+	file := testFlipnote(30)
+	decoder := NewAudioDecoder(file)
+
+	master, err := decoder.GetAudioMasterPcm(32768)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stem, err := decoder.GetAudioStemPcm(32768, Master)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalSamples(stem, master) {
+		t.Error("the Master stem differs from the master mix")
+	}
+
+	if !decoder.hasTrack(Master) {
+		t.Error("a flipnote with audio has no Master track")
+	}
+
+	if _, err := decoder.GetAudioStemPcm(32768, Master+1); err == nil {
+		t.Error("an invalid track was accepted")
+	}
+
+	if err := file.Audio.ExportRawADPCM(&bytes.Buffer{}, Master); err == nil {
+		t.Error("raw ADPCM was exported for the master mix")
+	}
+
+	if err := file.Audio.setTrackData(Master, []byte{0}); err == nil {
+		t.Error("raw track data was stored as the master mix")
+	}
+}
@@ -1,13 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"image"
-	"image/gif"
 	"os"
 	"time"
 
-	"github.com/Clinet/ffgoconv"
 	"github.com/RinLovesYou/ppmlib-go"
 )
 
@@ -37,23 +35,15 @@ func main() {
 	fmt.Printf("PPM: Parsed %s in %dms!\n", name, time.Since(timePPM).Milliseconds())
 
 	timeGIF := time.Now()
-	images := make([]*image.Paletted, ppm.FrameCount)
-	for i := uint16(0); i < ppm.FrameCount; i++ {
-		images[i] = ppm.Frames[i].GetImage()
-	}
-
 	gifFile, err := os.Create(nameGIF)
 	if err != nil {
 		panic(err)
 	}
 	defer gifFile.Close()
 
-	timings := make([]int, ppm.FrameCount)
-
-	gif.EncodeAll(gifFile, &gif.GIF{
-		Image: images,
-		Delay: timings,
-	})
+	if err := ppm.ExportGIF(gifFile, nil); err != nil {
+		panic(err)
+	}
 	fmt.Printf("GIF: Encoded %s in %dms!\n", name, time.Since(timeGIF).Milliseconds())
 
 	timeWAV := time.Now()
@@ -67,29 +57,14 @@ func main() {
 	fmt.Printf("WAV: Encoded %s in %dms!\n", name, time.Since(timeWAV).Milliseconds())
 
 	timeMP4 := time.Now()
-	ffmpeg, err := ffgoconv.NewFFmpeg(name, []string{"-hide_banner", "-stats",
-		"-r", fmt.Sprintf("%.1f", ppm.Framerate),
-		"-hwaccel", "auto",
-		"-i", nameGIF,
-		"-i", nameWAV,
-		nameMP4,
-		"-pix_fmt", "yuv420p",
-		"-c:v", "libx264",
-		"-c:a", "aac",
-		"-threads", "0",
-	})
+	videoFile, err := os.Create(nameMP4)
 	if err != nil {
 		panic(err)
 	}
+	defer videoFile.Close()
 
-	for {
-		if !ffmpeg.IsRunning() {
-			break
-		}
-		time.Sleep(time.Millisecond * 1)
-	}
-	if ffmpeg.Err() != nil {
-		panic(ffmpeg.Err())
+	if err := ppm.ExportVideo(context.Background(), videoFile, nil); err != nil {
+		panic(err)
 	}
 	fmt.Printf("MP4: Encoded %s in %dms!\n", name, time.Since(timeMP4).Milliseconds())
 
@@ -0,0 +1,104 @@
+package ppmlib
+
+import (
+	"errors"
+	"math"
+)
+
+// RenderOptions are the rendering settings shared by the exporters.
+type RenderOptions struct {
+	// Scale is an integer upscale factor, 1 when zero.
+	Scale int
+	// Palette is PaletteDefault when nil.
+	Palette *Palette
+	// Flags overrides the flipnote's AnimationFlags when set.
+	Flags *AnimationFlags
+}
+
+// FrameRange selects the exported frames, End is exclusive. An End of zero
+// exports up to the last frame.
+type FrameRange struct {
+	Start int
+	End   int
+}
+
+func (f *PPMFile) frameRange(start, end int) (int, int, error) {
+	if end == 0 {
+		end = len(f.Frames)
+	}
+
+	if start < 0 || end > len(f.Frames) || start >= end {
+		return 0, 0, errors.New("invalid frame range")
+	}
+
+	return start, end, nil
+}
+
+// exportScale validates an integer scale factor, treating zero as 1.
+func exportScale(scale int) (int, error) {
+	if scale == 0 {
+		return 1, nil
+	}
+
+	if scale < 0 {
+		return 0, errors.New("invalid scale")
+	}
+
+	return scale, nil
+}
+
+// NewRenderer returns a renderer that hides the layers the flipnote's
+// AnimationFlags hide.
+func (f *PPMFile) NewRenderer() *Renderer {
+	return f.renderer(0, nil, nil)
+}
+
+// animationFlags returns override when it is set, the flipnote's own flags
+// otherwise. A nil flipnote, for frames that do not belong to one, has no
+// flags set.
+func (f *PPMFile) animationFlags(override *AnimationFlags) AnimationFlags {
+	if override != nil {
+		return *override
+	}
+
+	if f == nil {
+		return AnimationFlags{}
+	}
+
+	return f.AnimationFlags
+}
+
+func (f *PPMFile) renderer(scale int, palette *Palette, flags *AnimationFlags) *Renderer {
+	animationFlags := f.animationFlags(flags)
+
+	return &Renderer{
+		Scale:      scale,
+		Palette:    palette,
+		HideLayer1: animationFlags.HideLayer1,
+		HideLayer2: animationFlags.HideLayer2,
+	}
+}
+
+// frameDelays converts the framerate into per frame delays in units of
+// 1/unitsPerSecond. Rounding errors are carried over to the next frame, so
+// the total duration stays exact.
+func frameDelays(framerate float32, count int, unitsPerSecond float64) []int {
+	delays := make([]int, count)
+	frameTime := unitsPerSecond / float64(framerate)
+
+	for i := range delays {
+		delays[i] = int(math.Round(float64(i+1)*frameTime) - math.Round(float64(i)*frameTime))
+	}
+
+	return delays
+}
+
+// framerateFraction returns the framerate as the fraction num/den.
+func framerateFraction(framerate float32) (int, int) {
+	num, den := int(math.Round(float64(framerate)*2)), 2
+	if num%den == 0 {
+		return num / den, 1
+	}
+
+	return num, den
+}
@@ -0,0 +1,355 @@
+package ppmlib
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	flacBlockSize         = 4096
+	flacMaxPartitionOrder = 8
+	flacMaxRiceParameter  = 14
+)
+
+// encodeFlac writes mono 16-bit samples as a FLAC stream. Every block is
+// stored as a constant subframe, a fixed predictor subframe with rice coded
+// residuals, or verbatim, whichever is smallest.
+func encodeFlac(writer io.Writer, pcm []int16, sampleRate int) error {
+	if sampleRate >= 1<<20 {
+		return errors.New("sample rate is too high for flac")
+	}
+
+	frames := &bytes.Buffer{}
+	minFrameSize, maxFrameSize := 0, 0
+
+	for i, frameNumber := 0, 0; i < len(pcm); i, frameNumber = i+flacBlockSize, frameNumber+1 {
+		end := i + flacBlockSize
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+
+		frame := encodeFlacFrame(pcm[i:end], frameNumber)
+		if minFrameSize == 0 || len(frame) < minFrameSize {
+			minFrameSize = len(frame)
+		}
+		if len(frame) > maxFrameSize {
+			maxFrameSize = len(frame)
+		}
+
+		frames.Write(frame)
+	}
+
+	// the block size in STREAMINFO may not be below 16, a shorter stream is
+	// still valid since its only block is also the last one
+	blockSize := flacBlockSize
+	if len(pcm) < blockSize {
+		blockSize = len(pcm)
+	}
+	if blockSize < 16 {
+		blockSize = 16
+	}
+
+	hash := md5.New()
+	binary.Write(hash, binary.LittleEndian, pcm)
+
+	info := &bitWriter{}
+	info.write(uint64(blockSize), 16)
+	info.write(uint64(blockSize), 16)
+	info.write(uint64(minFrameSize), 24)
+	info.write(uint64(maxFrameSize), 24)
+	info.write(uint64(sampleRate), 20)
+	info.write(0, 3)
+	info.write(15, 5)
+	info.write(uint64(len(pcm)), 36)
+
+	out := &bytes.Buffer{}
+	out.WriteString("fLaC")
+	// last metadata block flag, STREAMINFO type and its 34 byte length
+	out.Write([]byte{0x80, 0, 0, 34})
+	out.Write(info.bytes())
+	out.Write(hash.Sum(nil))
+	out.Write(frames.Bytes())
+
+	_, err := writer.Write(out.Bytes())
+	return err
+}
+
+func encodeFlacFrame(samples []int16, frameNumber int) []byte {
+	header := &bitWriter{}
+	// sync code, fixed block size strategy
+	header.write(0xFFF8, 16)
+	// block size stored as a 16-bit value at the end of the header,
+	// sample rate taken from STREAMINFO
+	header.write(0x7, 4)
+	header.write(0x0, 4)
+	// mono, 16 bits per sample
+	header.write(0x0, 4)
+	header.write(0x4, 3)
+	header.write(0, 1)
+	header.writeBytes(flacUtf8(uint64(frameNumber)))
+	header.write(uint64(len(samples)-1), 16)
+	header.write(uint64(crc8(header.bytes())), 8)
+
+	frame := header
+	writeFlacSubframe(frame, samples)
+	frame.align()
+	frame.write(uint64(crc16(frame.bytes())), 16)
+
+	return frame.bytes()
+}
+
+func writeFlacSubframe(w *bitWriter, samples []int16) {
+	constant := true
+	for _, sample := range samples {
+		if sample != samples[0] {
+			constant = false
+			break
+		}
+	}
+
+	if constant {
+		w.write(0, 8)
+		w.write(uint64(uint16(samples[0])), 16)
+		return
+	}
+
+	bestOrder := -1
+	bestCost := 16 * len(samples)
+	var bestResidual []int32
+	var bestPartitions []int
+
+	for order := 0; order <= 4 && order < len(samples); order++ {
+		residual := flacFixedResidual(samples, order)
+		partitions, cost := flacRiceParameters(residual, order, len(samples))
+		cost += 16 * order
+
+		if cost < bestCost {
+			bestOrder = order
+			bestCost = cost
+			bestResidual = residual
+			bestPartitions = partitions
+		}
+	}
+
+	if bestOrder < 0 {
+		w.write(0x2, 8)
+		for _, sample := range samples {
+			w.write(uint64(uint16(sample)), 16)
+		}
+		return
+	}
+
+	w.write(uint64(0x08|bestOrder)<<1, 8)
+	for _, sample := range samples[:bestOrder] {
+		w.write(uint64(uint16(sample)), 16)
+	}
+
+	partitionOrder := 0
+	for 1<<partitionOrder < len(bestPartitions) {
+		partitionOrder++
+	}
+
+	// rice coding with 4-bit parameters
+	w.write(0, 2)
+	w.write(uint64(partitionOrder), 4)
+
+	partitionSize := len(samples) >> partitionOrder
+	pos := bestOrder
+	for i, parameter := range bestPartitions {
+		end := (i + 1) * partitionSize
+		w.write(uint64(parameter), 4)
+
+		for ; pos < end; pos++ {
+			w.writeRice(bestResidual[pos], uint(parameter))
+		}
+	}
+}
+
+func flacFixedResidual(samples []int16, order int) []int32 {
+	residual := make([]int32, len(samples))
+
+	for i := order; i < len(samples); i++ {
+		x0 := int32(samples[i])
+
+		switch order {
+		case 0:
+			residual[i] = x0
+		case 1:
+			residual[i] = x0 - int32(samples[i-1])
+		case 2:
+			residual[i] = x0 - 2*int32(samples[i-1]) + int32(samples[i-2])
+		case 3:
+			residual[i] = x0 - 3*int32(samples[i-1]) + 3*int32(samples[i-2]) - int32(samples[i-3])
+		case 4:
+			residual[i] = x0 - 4*int32(samples[i-1]) + 6*int32(samples[i-2]) - 4*int32(samples[i-3]) + int32(samples[i-4])
+		}
+	}
+
+	return residual
+}
+
+// flacRiceParameters picks the partition order and per partition rice
+// parameters with the smallest estimated size, and returns that size in bits.
+func flacRiceParameters(residual []int32, order int, blockSize int) ([]int, int) {
+	var bestParameters []int
+	bestCost := -1
+
+	for partitionOrder := 0; partitionOrder <= flacMaxPartitionOrder; partitionOrder++ {
+		partitionCount := 1 << partitionOrder
+		if blockSize%partitionCount != 0 || blockSize/partitionCount <= order {
+			break
+		}
+
+		partitionSize := blockSize / partitionCount
+		parameters := make([]int, partitionCount)
+		cost := 6
+
+		for i := 0; i < partitionCount; i++ {
+			start := i * partitionSize
+			if start < order {
+				start = order
+			}
+			end := (i + 1) * partitionSize
+
+			var sum uint64
+			for _, r := range residual[start:end] {
+				sum += uint64(zigzag(r))
+			}
+
+			parameter, bits := riceParameter(sum, end-start)
+			parameters[i] = parameter
+			cost += 4 + bits
+		}
+
+		if bestCost < 0 || cost < bestCost {
+			bestCost = cost
+			bestParameters = parameters
+		}
+	}
+
+	return bestParameters, bestCost
+}
+
+func riceParameter(sum uint64, count int) (int, int) {
+	bestParameter, bestBits := 0, -1
+
+	for k := 0; k <= flacMaxRiceParameter; k++ {
+		bits := int(sum>>uint(k)) + count*(k+1)
+		if bestBits < 0 || bits < bestBits {
+			bestParameter = k
+			bestBits = bits
+		}
+	}
+
+	return bestParameter, bestBits
+}
+
+func zigzag(value int32) uint32 {
+	return uint32(value<<1) ^ uint32(value>>31)
+}
+
+func flacUtf8(value uint64) []byte {
+	if value < 0x80 {
+		return []byte{byte(value)}
+	}
+
+	length := 2
+	for value >= 1<<(5*length+1) {
+		length++
+	}
+
+	res := make([]byte, length)
+	for i := length - 1; i > 0; i-- {
+		res[i] = 0x80 | byte(value&0x3F)
+		value >>= 6
+	}
+	res[0] = byte(0xFF<<(8-length)) | byte(value)
+
+	return res
+}
+
+func crc8(data []byte) byte {
+	crc := byte(0)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	crc := uint16(0)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// bitWriter packs values most significant bit first.
+type bitWriter struct {
+	buf   []byte
+	acc   byte
+	count uint
+}
+
+func (w *bitWriter) write(value uint64, bits uint) {
+	for bits > 0 {
+		bits--
+		w.acc = w.acc<<1 | byte(value>>bits&1)
+		w.count++
+
+		if w.count == 8 {
+			w.buf = append(w.buf, w.acc)
+			w.acc, w.count = 0, 0
+		}
+	}
+}
+
+func (w *bitWriter) writeBytes(data []byte) {
+	for _, b := range data {
+		w.write(uint64(b), 8)
+	}
+}
+
+func (w *bitWriter) writeRice(value int32, parameter uint) {
+	u := zigzag(value)
+
+	for q := u >> parameter; q > 0; {
+		n := q
+		if n > 32 {
+			n = 32
+		}
+		w.write(0, uint(n))
+		q -= n
+	}
+	w.write(1, 1)
+	w.write(uint64(u), parameter)
+}
+
+func (w *bitWriter) align() {
+	if w.count > 0 {
+		w.write(0, 8-w.count)
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
@@ -0,0 +1,91 @@
+package ppmlib
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlacStreamInfo(t *testing.T) {
+	for name, pcm := range testSignals() {
+		for _, rate := range []int{8192, 32768, 44100} {
+			buf := &bytes.Buffer{}
+			if err := EncodePcm(buf, pcm, rate, AudioFormatFLAC); err != nil {
+				t.Fatalf("%s %d: %v", name, rate, err)
+			}
+
+			data := buf.Bytes()
+			if len(data) < 42 || string(data[:4]) != "fLaC" || data[4]&0x7F != 0 {
+				t.Fatalf("%s %d: stream does not start with a STREAMINFO block", name, rate)
+			}
+
+			info := data[8:42]
+			minBlock := binary.BigEndian.Uint16(info[0:])
+			maxBlock := binary.BigEndian.Uint16(info[2:])
+			fields := binary.BigEndian.Uint64(info[10:])
+			sampleRate := int(fields >> 44)
+			channels := int(fields>>41&0x7) + 1
+			bitsPerSample := int(fields>>36&0x1F) + 1
+			samples := int(fields & 0xFFFFFFFFF)
+
+			if minBlock < 16 || maxBlock < minBlock {
+				t.Errorf("%s %d: block sizes %d to %d", name, rate, minBlock, maxBlock)
+			}
+			if sampleRate != rate || channels != 1 || bitsPerSample != 16 || samples != len(pcm) {
+				t.Errorf("%s %d: stream info %d Hz, %d channels, %d bits, %d samples", name, rate, sampleRate, channels, bitsPerSample, samples)
+			}
+
+			hash := md5.New()
+			binary.Write(hash, binary.LittleEndian, pcm)
+			if !bytes.Equal(hash.Sum(nil), info[18:34]) {
+				t.Errorf("%s %d: stream info md5 does not match the samples", name, rate)
+			}
+		}
+	}
+}
+
+// TestFlacDecode decodes the encoder's output with the reference flac tool,
+// which also checks the stream's md5.
+func TestFlacDecode(t *testing.T) {
+	path, err := exec.LookPath("flac")
+	if err != nil {
+		t.Skip("flac is not installed")
+	}
+
+	dir := t.TempDir()
+	for name, pcm := range testSignals() {
+		for _, rate := range []int{8192, 32768, 44100} {
+			buf := &bytes.Buffer{}
+			if err := EncodePcm(buf, pcm, rate, AudioFormatFLAC); err != nil {
+				t.Fatalf("%s %d: %v", name, rate, err)
+			}
+
+			in := filepath.Join(dir, "in.flac")
+			out := filepath.Join(dir, "out.raw")
+			if err := os.WriteFile(in, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command(path, "-d", "-s", "-f", "--force-raw-format", "--endian=little", "--sign=signed", "-o", out, in)
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%s %d: %v: %s", name, rate, err, output)
+			}
+
+			raw, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded := make([]int16, len(raw)/2)
+			binary.Read(bytes.NewReader(raw), binary.LittleEndian, decoded)
+
+			if !equalSamples(decoded, pcm) {
+				t.Errorf("%s %d: samples differ after decoding", name, rate)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package ppmlib
+
+const (
+	animationFlagLoop       = 0x2
+	animationFlagHideLayer1 = 0x10
+	animationFlagHideLayer2 = 0x20
+)
+
+// AnimationFlags are the playback settings stored in the animation header.
+type AnimationFlags struct {
+	Loop       bool
+	HideLayer1 bool
+	HideLayer2 bool
+
+	// Other holds the remaining bits as they were read, so they are written
+	// back unchanged.
+	Other uint16
+}
+
+func NewAnimationFlags(val uint16) AnimationFlags {
+	return AnimationFlags{
+		Loop:       val&animationFlagLoop != 0,
+		HideLayer1: val&animationFlagHideLayer1 != 0,
+		HideLayer2: val&animationFlagHideLayer2 != 0,
+		Other:      val &^ (animationFlagLoop | animationFlagHideLayer1 | animationFlagHideLayer2),
+	}
+}
+
+// Value returns the flags as stored in the file.
+func (a AnimationFlags) Value() uint16 {
+	val := a.Other &^ (animationFlagLoop | animationFlagHideLayer1 | animationFlagHideLayer2)
+
+	if a.Loop {
+		val |= animationFlagLoop
+	}
+	if a.HideLayer1 {
+		val |= animationFlagHideLayer1
+	}
+	if a.HideLayer2 {
+		val |= animationFlagHideLayer2
+	}
+
+	return val
+}
@@ -0,0 +1,151 @@
+package ppmlib
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAnimationFlagsRoundTrip(t *testing.T) {
+	for _, value := range []uint16{0, 0x2, 0x10, 0x20, 0x43, 0xFFFF} {
+		if got := NewAnimationFlags(value).Value(); got != value {
+			t.Errorf("0x%x came back as 0x%x", value, got)
+		}
+	}
+}
+
+// hiddenLayerFlipnote returns a flipnote whose flags hide layer 1, with the
+// first frame drawn at 0,10 on layer 1 and 0,20 on layer 2.
+func hiddenLayerFlipnote() *PPMFile {
+	file := testFlipnote(3)
+	file.AnimationFlags.HideLayer1 = true
+
+	return file
+}
+
+func TestFrameExportsUseTheFlipnoteFlags(t *testing.T) {
+	file := hiddenLayerFlipnote()
+	frame := file.Frames[0]
+
+	// the paper is black, layer 1 is drawn in white
+	img := frame.GetImage()
+	if img.ColorIndexAt(0, 10) != paletteIndexBlack || img.ColorIndexAt(0, 20) != paletteIndexRed {
+		t.Error("GetImage does not hide layer 1")
+	}
+
+	// a frame of no flipnote draws both layers
+	img = testFrames(1)[0].GetImage()
+	if img.ColorIndexAt(0, 10) != paletteIndexWhite {
+		t.Error("GetImage hides a layer of a frame without flipnote")
+	}
+
+	hidden, shown := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := frame.ExportSVG(hidden, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := frame.ExportSVG(shown, &SVGOptions{RenderOptions: RenderOptions{Flags: &AnimationFlags{}}}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(hidden.String(), "<path") >= strings.Count(shown.String(), "<path") {
+		t.Error("ExportSVG draws layer 1")
+	}
+
+	for _, test := range []struct {
+		name  string
+		flags *AnimationFlags
+		want  string
+	}{
+		{"flipnote", nil, "hidden"},
+		{"override", &AnimationFlags{}, "visible"},
+	} {
+		buf := &bytes.Buffer{}
+		if err := frame.ExportORA(buf, &ORAOptions{RenderOptions: RenderOptions{Flags: test.flags}}); err != nil {
+			t.Fatal(err)
+		}
+
+		stack := oraStack(t, buf.Bytes())
+		if !strings.Contains(stack, `name="Layer 1" src="data/layer1.png" x="0" y="0" opacity="1.0" visibility="`+test.want+`"`) {
+			t.Errorf("%s: layer 1 is not %s in\n%s", test.name, test.want, stack)
+		}
+	}
+}
+
+func oraStack(t *testing.T, data []byte) string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stack, err := zr.Open("stack.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stack.Close()
+
+	xml, err := io.ReadAll(stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(xml)
+}
+
+func TestNPYHidesLayers(t *testing.T) {
+	file := hiddenLayerFlipnote()
+
+	for _, test := range []struct {
+		name      string
+		flags     *AnimationFlags
+		hasLayer1 bool
+	}{
+		{"flipnote", nil, false},
+		{"override", &AnimationFlags{}, true},
+	} {
+		_, data, err := file.npyLayers(&NPYOptions{Layout: NPYLayoutLayers, Flags: test.flags})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hasLayer1 := data[10*256] == 1; hasLayer1 != test.hasLayer1 {
+			t.Errorf("%s: layer 1 exported is %v, want %v", test.name, hasLayer1, test.hasLayer1)
+		}
+		if data[256*192+20*256] != 1 {
+			t.Errorf("%s: layer 2 is missing", test.name)
+		}
+	}
+}
+
+func TestAsepriteExportHidesLayers(t *testing.T) {
+	file := hiddenLayerFlipnote()
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		flags  *AnimationFlags
+		hidden bool
+	}{
+		{"flipnote", nil, true},
+		{"override", &AnimationFlags{}, false},
+	} {
+		buf := &bytes.Buffer{}
+		if err := file.ExportAseprite(buf, &AsepriteExportOptions{Flags: test.flags}); err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := FromAseprite(buf, &AsepriteImportOptions{Author: author})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if imported.AnimationFlags.HideLayer1 != test.hidden {
+			t.Errorf("%s: layer 1 hidden is %v, want %v", test.name, imported.AnimationFlags.HideLayer1, test.hidden)
+		}
+	}
+}
@@ -16,6 +16,10 @@ type Frame struct {
 	Layer2 *Layer
 
 	AnimationIndex int
+
+	// file is the flipnote the frame was parsed or created with. Its
+	// AnimationFlags apply to the frame's own exports.
+	file *PPMFile
 }
 
 func NewFrame() *Frame {
@@ -25,6 +29,15 @@ func NewFrame() *Frame {
 	}
 }
 
+// clone returns a copy of the frame that shares no layer data with it.
+func (f *Frame) clone() *Frame {
+	frame := *f
+	frame.Layer1 = f.Layer1.clone()
+	frame.Layer2 = f.Layer2.clone()
+
+	return &frame
+}
+
 func ReadFrame(buffer *crunch.Buffer) *Frame {
 	frame := NewFrame()
 
@@ -0,0 +1,234 @@
+package ppmlib
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type FramesOptions struct {
+	Format ImageFormat
+	// Template names the files. {filename} is the flipnote's current
+	// filename, {index} the frame index, optionally zero padded as in
+	// {index:04}, and {ext} the format's extension. It must contain {index},
+	// no path separators, and defaults to "{filename}_{index:04}.{ext}".
+	Template string
+	RenderOptions
+	FrameRange
+	// Step exports every Step-th frame of the range, 1 when zero.
+	Step int
+	// TransparentPaper leaves the paper fully transparent. PNG only.
+	TransparentPaper bool
+	// Quality is the JPEG quality from 1 to 100, 90 when zero.
+	Quality int
+	// Workers is the number of frames encoded at once, runtime.NumCPU()
+	// when zero.
+	Workers int
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+
+var imageFormatExtensions = map[ImageFormat]string{
+	ImageFormatPNG:  "png",
+	ImageFormatJPEG: "jpg",
+}
+
+// ExportFrames writes frames of the flipnote to dir as separate images.
+func (f *PPMFile) ExportFrames(dir string, opts *FramesOptions) error {
+	if opts == nil {
+		opts = &FramesOptions{}
+	}
+
+	start, end, err := f.frameRange(opts.Start, opts.End)
+	if err != nil {
+		return err
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	ext, ok := imageFormatExtensions[opts.Format]
+	if !ok {
+		return errors.New("invalid image format")
+	}
+
+	if opts.TransparentPaper && opts.Format != ImageFormatPNG {
+		return errors.New("transparent paper requires png")
+	}
+
+	step := opts.Step
+	if step == 0 {
+		step = 1
+	}
+	if step < 0 {
+		return errors.New("invalid step")
+	}
+
+	quality := opts.Quality
+	if quality == 0 {
+		quality = 90
+	}
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers < 0 {
+		return errors.New("invalid worker count")
+	}
+
+	template := opts.Template
+	if template == "" {
+		template = "{filename}_{index:04}.{ext}"
+	}
+
+	filename := ""
+	if f.CurrentFilename != nil {
+		filename = f.CurrentFilename.String()
+	}
+
+	// check the template once, so workers only fail on i/o
+	if _, err := expandTemplate(template, filename, 0, ext); err != nil {
+		return err
+	}
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	renderer.TransparentPaper = opts.TransparentPaper
+
+	indices := make(chan int)
+	done := make(chan struct{})
+	once := &sync.Once{}
+	wg := &sync.WaitGroup{}
+
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(done)
+		})
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range indices {
+				name, _ := expandTemplate(template, filename, index, ext)
+				if err := f.writeFrameImage(filepath.Join(dir, name), index, renderer, opts.Format, quality); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+queue:
+	for i := start; i < end; i += step {
+		select {
+		case indices <- i:
+		case <-done:
+			break queue
+		}
+	}
+
+	close(indices)
+	wg.Wait()
+
+	return firstErr
+}
+
+func (f *PPMFile) writeFrameImage(path string, index int, renderer *Renderer, format ImageFormat, quality int) error {
+	var img image.Image
+	var err error
+
+	if format == ImageFormatPNG {
+		img, err = renderer.RenderPaletted(f.Frames[index], nil)
+	} else {
+		img, err = renderer.RenderRGBA(f.Frames[index], nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if format == ImageFormatPNG {
+		err = png.Encode(file, img)
+	} else {
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: quality})
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// expandTemplate fills in the placeholders of a frame file name template.
+func expandTemplate(template string, filename string, index int, ext string) (string, error) {
+	var err error
+	hasIndex := false
+
+	name := templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		match := templatePlaceholder.FindStringSubmatch(placeholder)
+		key, width := match[1], match[2]
+
+		if key == "index" {
+			hasIndex = true
+			if width == "" {
+				return strconv.Itoa(index)
+			}
+
+			n, _ := strconv.Atoi(width)
+			return fmt.Sprintf("%0*d", n, index)
+		}
+
+		if width != "" {
+			err = fmt.Errorf("placeholder %s does not take a width", placeholder)
+		}
+
+		switch key {
+		case "filename":
+			return filename
+		case "ext":
+			return ext
+		}
+
+		err = fmt.Errorf("unknown placeholder %s", placeholder)
+		return placeholder
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if strings.ContainsAny(templatePlaceholder.ReplaceAllString(template, ""), "{}") {
+		return "", fmt.Errorf("invalid placeholder in %q", template)
+	}
+
+	if strings.ContainsAny(name, `/\`) {
+		return "", errors.New("frame file names can not contain path separators")
+	}
+
+	if !hasIndex {
+		return "", errors.New("template must contain {index}")
+	}
+
+	return name, nil
+}
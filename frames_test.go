@@ -0,0 +1,155 @@
+package ppmlib
+
+import (
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+		ok       bool
+	}{
+		{"{filename}_{index:04}.{ext}", "F00D_0007.png", true},
+		{"{index}.{ext}", "7.png", true},
+		{"frame-{index:2}-{index}", "frame-07-7", true},
+		{"{index:1}", "7", true},
+		{"{filename}.{ext}", "", false},
+		{"{frame}_{index}", "", false},
+		{"{ext:3}_{index}", "", false},
+		{"{index:x}_{index}", "", false},
+		{"{index", "", false},
+		{"index}_{index}", "", false},
+		{"frames/{index}", "", false},
+		{`frames\{index}`, "", false},
+		{"../{index}", "", false},
+	}
+
+	for _, test := range tests {
+		got, err := expandTemplate(test.template, "F00D", 7, "png")
+		if test.ok && err != nil {
+			t.Errorf("%q: %v", test.template, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%q: expected an error, got %q", test.template, got)
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestExportFrames(t *testing.T) {
+	file := testFlipnote(10)
+
+	tests := []struct {
+		name string
+		opts *FramesOptions
+		want []string
+	}{
+		{"range and step", &FramesOptions{
+			Template:   "f{index:03}.{ext}",
+			FrameRange: FrameRange{Start: 2, End: 9},
+			Step:       3,
+			Workers:    2,
+		}, []string{"f002.png", "f005.png", "f008.png"}},
+		{"jpeg", &FramesOptions{
+			Format:     ImageFormatJPEG,
+			Template:   "{index}.{ext}",
+			FrameRange: FrameRange{End: 3},
+		}, []string{"0.jpg", "1.jpg", "2.jpg"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := file.ExportFrames(dir, test.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+			sort.Strings(names)
+
+			if len(names) != len(test.want) {
+				t.Fatalf("wrote %v, want %v", names, test.want)
+			}
+			for i, name := range names {
+				if name != test.want[i] {
+					t.Fatalf("wrote %v, want %v", names, test.want)
+				}
+
+				f, err := os.Open(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if test.opts.Format == ImageFormatJPEG {
+					_, err = jpeg.Decode(f)
+				} else {
+					_, err = png.Decode(f)
+				}
+				f.Close()
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+		})
+	}
+
+	t.Run("default template", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := file.ExportFrames(dir, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 10 {
+			t.Fatalf("wrote %d files, want 10", len(entries))
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, file.CurrentFilename.String()+"_0009.png")); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestExportFramesErrors(t *testing.T) {
+	file := testFlipnote(4)
+
+	tests := []struct {
+		name string
+		dir  string
+		opts *FramesOptions
+	}{
+		{"missing directory", filepath.Join(t.TempDir(), "missing"), nil},
+		{"bad template", t.TempDir(), &FramesOptions{Template: "{name}_{index}"}},
+		{"path in template", t.TempDir(), &FramesOptions{Template: "x/{index}.png"}},
+		{"invalid format", t.TempDir(), &FramesOptions{Format: ImageFormat(9)}},
+		{"transparent jpeg", t.TempDir(), &FramesOptions{Format: ImageFormatJPEG, TransparentPaper: true}},
+		{"negative step", t.TempDir(), &FramesOptions{Step: -1}},
+		{"negative workers", t.TempDir(), &FramesOptions{Workers: -1}},
+		{"invalid range", t.TempDir(), &FramesOptions{FrameRange: FrameRange{Start: 3, End: 2}}},
+	}
+
+	for _, test := range tests {
+		if err := file.ExportFrames(test.dir, test.opts); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ppmlib
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+type GIFOptions struct {
+	RenderOptions
+	FrameRange
+	// Optimize merges runs of identical frames and crops every other frame
+	// to the area that changed, leaving unchanged pixels transparent.
+	Optimize bool
+}
+
+// ExportGIF writes the flipnote as an animated GIF. Frame delays follow the
+// flipnote's framerate and it loops only if the flipnote does.
+func (f *PPMFile) ExportGIF(w io.Writer, opts *GIFOptions) error {
+	if opts == nil {
+		opts = &GIFOptions{}
+	}
+
+	start, end, err := f.frameRange(opts.Start, opts.End)
+	if err != nil {
+		return err
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	images := make([]*image.Paletted, 0, end-start)
+	for i := start; i < end; i++ {
+		img, err := renderer.RenderPaletted(f.Frames[i], nil)
+		if err != nil {
+			return err
+		}
+		images = append(images, img)
+	}
+
+	loopCount := -1
+	if f.animationFlags(opts.Flags).Loop {
+		loopCount = 0
+	}
+
+	anim := &gif.GIF{
+		Image:     images,
+		Delay:     frameDelays(f.Framerate, end-start, 100),
+		LoopCount: loopCount,
+	}
+
+	if opts.Optimize {
+		optimizeGIF(anim)
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// optimizeGIF merges identical consecutive frames into one with a longer
+// delay, then replaces every frame after the first with the bounding box of
+// the pixels that changed, drawn over the previous frame.
+func optimizeGIF(anim *gif.GIF) {
+	images := anim.Image[:1]
+	delays := anim.Delay[:1]
+
+	for i := 1; i < len(anim.Image); i++ {
+		if bytes.Equal(anim.Image[i].Pix, images[len(images)-1].Pix) {
+			delays[len(delays)-1] += anim.Delay[i]
+			continue
+		}
+
+		images = append(images, anim.Image[i])
+		delays = append(delays, anim.Delay[i])
+	}
+
+	bounds := images[0].Bounds()
+	palette := append(color.Palette{}, images[0].Palette...)
+	transparent := uint8(len(palette))
+	palette = append(palette, color.RGBA{})
+
+	cropped := make([]*image.Paletted, len(images))
+	cropped[0] = &image.Paletted{
+		Pix:     images[0].Pix,
+		Stride:  images[0].Stride,
+		Rect:    bounds,
+		Palette: palette,
+	}
+
+	for i := 1; i < len(images); i++ {
+		prev, cur := images[i-1], images[i]
+		minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X, bounds.Min.Y
+
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				offset := cur.PixOffset(x, y)
+				if cur.Pix[offset] == prev.Pix[offset] {
+					continue
+				}
+
+				if x < minX {
+					minX = x
+				}
+				if x >= maxX {
+					maxX = x + 1
+				}
+				if y < minY {
+					minY = y
+				}
+				maxY = y + 1
+			}
+		}
+		changed := image.Rect(minX, minY, maxX, maxY)
+
+		frame := image.NewPaletted(changed, palette)
+		for y := changed.Min.Y; y < changed.Max.Y; y++ {
+			for x := changed.Min.X; x < changed.Max.X; x++ {
+				offset := cur.PixOffset(x, y)
+				index := cur.Pix[offset]
+				if index == prev.Pix[offset] {
+					index = transparent
+				}
+
+				frame.Pix[frame.PixOffset(x, y)] = index
+			}
+		}
+
+		cropped[i] = frame
+	}
+
+	anim.Image = cropped
+	anim.Delay = delays
+	anim.Disposal = make([]byte, len(cropped))
+	for i := range anim.Disposal {
+		anim.Disposal[i] = gif.DisposalNone
+	}
+	anim.Config = image.Config{
+		ColorModel: palette,
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+	}
+}
@@ -0,0 +1,174 @@
+package ppmlib
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+func TestExportGIFDelays(t *testing.T) {
+	tests := []struct {
+		framerate float32
+		frames    int
+		duration  int
+	}{
+		{30, 30, 100},
+		{20, 40, 200},
+		{12, 36, 300},
+		// a range of 7 frames at 12 fps lasts 58.3 centiseconds
+		{12, 7, 58},
+	}
+
+	for _, test := range tests {
+		file := testFlipnote(test.frames)
+		file.Framerate = test.framerate
+
+		buf := &bytes.Buffer{}
+		if err := file.ExportGIF(buf, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		anim, err := gif.DecodeAll(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(anim.Delay) != test.frames {
+			t.Fatalf("%v fps: %d delays, want %d", test.framerate, len(anim.Delay), test.frames)
+		}
+
+		total := 0
+		for i, delay := range anim.Delay {
+			// every frame is shown for about 1/framerate, and the delays up to
+			// any frame add up to its exact start time
+			if exact := 100 / float64(test.framerate); float64(delay) < exact-1 || float64(delay) > exact+1 {
+				t.Errorf("%v fps: frame %d has a delay of %d", test.framerate, i, delay)
+			}
+
+			total += delay
+			if want := int(float64(i+1)*100/float64(test.framerate) + 0.5); total != want {
+				t.Errorf("%v fps: the first %d frames last %d, want %d", test.framerate, i+1, total, want)
+			}
+		}
+
+		if total != test.duration {
+			t.Errorf("%v fps: the delays add up to %d, want %d", test.framerate, total, test.duration)
+		}
+	}
+}
+
+func TestExportGIFLoop(t *testing.T) {
+	tests := []struct {
+		name  string
+		loop  bool
+		flags *AnimationFlags
+		want  int
+	}{
+		{"loops", true, nil, 0},
+		{"plays once", false, nil, -1},
+		{"override loops", false, &AnimationFlags{Loop: true}, 0},
+		{"override plays once", true, &AnimationFlags{}, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := testFlipnote(3)
+			file.AnimationFlags.Loop = test.loop
+
+			buf := &bytes.Buffer{}
+			if err := file.ExportGIF(buf, &GIFOptions{RenderOptions: RenderOptions{Flags: test.flags}}); err != nil {
+				t.Fatal(err)
+			}
+
+			anim, err := gif.DecodeAll(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if anim.LoopCount != test.want {
+				t.Errorf("LoopCount is %d, want %d", anim.LoopCount, test.want)
+			}
+		})
+	}
+}
+
+func TestExportGIFOptimize(t *testing.T) {
+	// frames 1 to 3 and 4 to 5 are identical and get merged
+	frames := testFrames(4)
+	file := testFlipnote(7)
+	file.Frames = []*Frame{frames[0], frames[1], frames[1].clone(), frames[1].clone(), frames[2], frames[2].clone(), frames[3]}
+	unique := []*Frame{frames[0], frames[1], frames[2], frames[3]}
+	groups := [][2]int{{0, 1}, {1, 4}, {4, 6}, {6, 7}}
+
+	opts := &GIFOptions{RenderOptions: RenderOptions{Scale: 2}}
+
+	buf := &bytes.Buffer{}
+	if err := file.ExportGIF(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Optimize = true
+	buf.Reset()
+	if err := file.ExportGIF(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anim.Image) != len(unique) {
+		t.Fatalf("%d frames, want %d after merging", len(anim.Image), len(unique))
+	}
+
+	renderer := file.renderer(2, nil, nil)
+	canvas := image.NewRGBA(renderer.Bounds())
+
+	for i, img := range anim.Image {
+		if anim.Disposal[i] != gif.DisposalNone {
+			t.Errorf("frame %d has disposal %d", i, anim.Disposal[i])
+		}
+
+		// frames after the first only cover the area that changed
+		if i > 0 && img.Rect == canvas.Rect {
+			t.Errorf("frame %d is not cropped", i)
+		}
+
+		draw.Draw(canvas, img.Rect, img, img.Rect.Min, draw.Over)
+
+		want, err := renderer.RenderPaletted(unique[i], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for y := 0; y < canvas.Rect.Dy(); y++ {
+			for x := 0; x < canvas.Rect.Dx(); x++ {
+				if !equalColor(canvas.At(x, y), want.At(x, y)) {
+					t.Fatalf("frame %d: pixel %d,%d is %v, want %v", i, x, y, canvas.At(x, y), want.At(x, y))
+				}
+			}
+		}
+
+		delay := 0
+		for _, d := range plain.Delay[groups[i][0]:groups[i][1]] {
+			delay += d
+		}
+		if anim.Delay[i] != delay {
+			t.Errorf("frame %d has a delay of %d, want %d", i, anim.Delay[i], delay)
+		}
+	}
+}
+
+func equalColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
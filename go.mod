@@ -3,26 +3,7 @@ module github.com/RinLovesYou/ppmlib-go
 go 1.18
 
 require (
-	github.com/3d0c/gmf v0.0.0-20220425074253-5646e6e80daf // indirect
-	github.com/Clinet/ffgoconv v0.0.0-20220721014858-40ddcf5c7535 // indirect
-	github.com/Ernyoke/Imger v0.0.0-20210929183401-55700becd332 // indirect
-	github.com/anthonynsimon/bild v0.13.0 // indirect
-	github.com/aws/aws-sdk-go v1.38.20 // indirect
-	github.com/disintegration/imaging v1.6.2 // indirect
-	github.com/fatih/structs v1.1.0 // indirect
-	github.com/go-audio/audio v1.0.0 // indirect
-	github.com/go-audio/riff v1.0.0 // indirect
-	github.com/go-audio/wav v1.1.0 // indirect
-	github.com/jmespath/go-jmespath v0.4.0 // indirect
-	github.com/makeworld-the-better-one/dither/v2 v2.2.0 // indirect
-	github.com/modfy/fluent-ffmpeg v0.1.0 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
-	github.com/superwhiskers/crunch/v3 v3.5.6 // indirect
-	github.com/u2takey/ffmpeg-go v0.4.1 // indirect
-	github.com/u2takey/go-utils v0.3.1 // indirect
-	github.com/youpy/go-riff v0.1.0 // indirect
-	github.com/youpy/go-wav v0.3.2 // indirect
-	github.com/zaf/g711 v0.0.0-20190814101024-76a4a538f52b // indirect
-	golang.org/x/image v0.0.0-20220617043117-41969df76e82 // indirect
-	golang.org/x/text v0.3.7 // indirect
+	github.com/superwhiskers/crunch/v3 v3.5.6
+	golang.org/x/image v0.5.0
+	golang.org/x/text v0.7.0
 )
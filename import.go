@@ -0,0 +1,176 @@
+package ppmlib
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// FrameFromImage converts an image into a keyframe. The image is scaled to
+// 256x192 and every pixel is snapped to the nearest flipnote color. The more
+// common of black and white becomes the paper, the other one is drawn on
+// layer 1 and the more common of red and blue on layer 2.
+func FrameFromImage(img image.Image) *Frame {
+	bounds := img.Bounds()
+	colors := []color.RGBA{white, black, red, blue}
+	indices := make([]int, 256*192)
+	counts := make([]int, len(colors))
+
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/256
+			sy := bounds.Min.Y + y*bounds.Dy()/192
+
+			index := nearestColor(img.At(sx, sy), colors)
+			indices[256*y+x] = index
+			if index >= 0 {
+				counts[index]++
+			}
+		}
+	}
+
+	frame := NewFrame()
+	paper, ink := 0, 1
+	if counts[1] > counts[0] {
+		paper, ink = 1, 0
+		frame.PaperColor = PaperColorBlack
+	} else {
+		frame.PaperColor = PaperColorWhite
+	}
+
+	frame.Layer1.PenColor = PenColorInverted
+	frame.Layer2.PenColor = PenColorRed
+	layer2 := 2
+	if counts[3] > counts[2] {
+		frame.Layer2.PenColor = PenColorBlue
+		layer2 = 3
+	}
+
+	// without any ink the spare layer can hold the less common pen color
+	layer1 := ink
+	if spare := 5 - layer2; counts[ink] == 0 && counts[spare] > 0 {
+		layer1 = spare
+		frame.Layer1.PenColor = PenColorRed
+		if spare == 3 {
+			frame.Layer1.PenColor = PenColorBlue
+		}
+	}
+
+	for i, index := range indices {
+		x, y := i%256, i/256
+
+		switch index {
+		case paper, -1:
+		case layer1:
+			frame.Layer1.Set(x, y, true)
+		default:
+			frame.Layer2.Set(x, y, true)
+		}
+	}
+
+	frame.FirstByteHeader = 0x80 | byte(frame.PaperColor) | byte(frame.Layer1.PenColor)<<1 | byte(frame.Layer2.PenColor)<<3
+
+	return frame
+}
+
+// nearestColor returns the index of the closest color, or -1 for pixels
+// that are mostly transparent.
+func nearestColor(c color.Color, colors []color.RGBA) int {
+	r, g, b, a := c.RGBA()
+	if a < 0x8000 {
+		return -1
+	}
+
+	best, bestDistance := 0, -1
+	for i, candidate := range colors {
+		dr := int(r>>8) - int(candidate.R)
+		dg := int(g>>8) - int(candidate.G)
+		db := int(b>>8) - int(candidate.B)
+
+		distance := dr*dr + dg*dg + db*db
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+
+	return best
+}
+
+// nearestFrameSpeed returns the flipnote frame speed closest to framerate.
+func nearestFrameSpeed(framerate float64) byte {
+	best := byte(1)
+	for speed := byte(1); speed <= 8; speed++ {
+		if math.Abs(float64(ppmFramerates[speed])-framerate) < math.Abs(float64(ppmFramerates[best])-framerate) {
+			best = speed
+		}
+	}
+
+	return best
+}
+
+// timeline retimes source frames of any duration to the fixed frame time of
+// a frame speed. Every output frame shows the source frame playing halfway
+// through it, so durations rounded to milliseconds still land in the right
+// frame.
+type timeline struct {
+	framerate float64
+	frames    []*Frame
+}
+
+func newTimeline(speed byte) *timeline {
+	return &timeline{framerate: float64(ppmFramerates[speed])}
+}
+
+// repeats returns how many output frames show the source frame that ends at
+// end seconds, following the previous one. It fails as soon as the flipnote
+// would get longer than 999 frames, before anything is added.
+func (t *timeline) repeats(end float64) (int, error) {
+	last := int(math.Ceil(end*t.framerate - 0.5))
+	if last > 999 {
+		return 0, errors.New("a flipnote can not be longer than 999 frames")
+	}
+
+	if last < len(t.frames) {
+		return 0, nil
+	}
+
+	return last - len(t.frames), nil
+}
+
+// add appends frame count times. Every repeat is its own copy, so editing
+// one of them leaves the others untouched.
+func (t *timeline) add(frame *Frame, count int) {
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			frame = frame.clone()
+		}
+
+		t.frames = append(t.frames, frame)
+	}
+}
+
+// createFromFrames builds a silent flipnote playing at the given speed.
+func createFromFrames(author *Author, frames []*Frame, speed byte) (*PPMFile, error) {
+	if author == nil {
+		return nil, errors.New("an author is required")
+	}
+
+	if len(frames) == 0 || len(frames) > 999 {
+		return nil, errors.New("a flipnote must have between 1 and 999 frames")
+	}
+
+	file, err := CreateFile(author, frames, make([]byte, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	file.FrameCount = uint16(len(frames))
+	file.Audio.Header.CurrentFrameSpeed = speed
+	file.Audio.Header.RecordingBGMFrameSpeed = speed
+	file.Framerate = ppmFramerates[speed]
+	file.BGMRate = ppmFramerates[speed]
+	file.SoundEffectFlags = make([]byte, len(frames))
+
+	return file, nil
+}
@@ -0,0 +1,67 @@
+package ppmlib
+
+import (
+	"testing"
+)
+
+func TestTimelineRepeats(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		speed  byte
+		ends   func(i int) float64
+		frames int
+		want   func(i int) int
+	}{
+		// 1000/12 rounded to milliseconds drifts by up to half a frame within
+		// five seconds, which must not drop or repeat a frame
+		{"rounded down milliseconds", 6, func(i int) float64 { return float64((i+1)*83) / 1000 }, 60, func(int) int { return 1 }},
+		{"rounded up milliseconds", 6, func(i int) float64 { return float64((i+1)*84) / 1000 }, 60, func(int) int { return 1 }},
+		{"halved framerate", 6, func(i int) float64 { return float64(i+1) / 24 }, 48, func(i int) int { return i % 2 }},
+		{"doubled framerate", 6, func(i int) float64 { return float64(i+1) / 6 }, 48, func(int) int { return 2 }},
+	} {
+		timeline := newTimeline(test.speed)
+
+		for i := 0; i < test.frames; i++ {
+			repeats, err := timeline.repeats(test.ends(i))
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			if repeats != test.want(i) {
+				t.Errorf("%s: source frame %d is shown %d times, want %d", test.name, i, repeats, test.want(i))
+				break
+			}
+
+			timeline.add(NewFrame(), repeats)
+		}
+	}
+}
+
+func TestTimelineLimit(t *testing.T) {
+	timeline := newTimeline(6)
+
+	if _, err := timeline.repeats(999.0 / 12); err != nil {
+		t.Errorf("999 frames were rejected: %v", err)
+	}
+
+	if _, err := timeline.repeats(1000.0 / 12); err == nil {
+		t.Error("1000 frames were accepted")
+	}
+}
+
+func TestTimelineCopiesRepeats(t *testing.T) {
+	timeline := newTimeline(6)
+	timeline.add(testFrames(1)[0], 3)
+
+	timeline.frames[1].Layer1.Set(100, 100, true)
+
+	for _, i := range []int{0, 2} {
+		if timeline.frames[i] == timeline.frames[1] || timeline.frames[i].Layer1.Get(100, 100) {
+			t.Errorf("repeat %d shares its layers with repeat 1", i)
+		}
+	}
+
+	if !timeline.frames[2].Layer1.Get(10, 10) {
+		t.Error("a repeat lost the frame's drawing")
+	}
+}
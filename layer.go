@@ -14,6 +14,14 @@ func NewLayer() *Layer {
 	}
 }
 
+func (l *Layer) clone() *Layer {
+	return &Layer{
+		PenColor:      l.PenColor,
+		linesEncoding: append([]byte(nil), l.linesEncoding...),
+		layerData:     append([]byte(nil), l.layerData...),
+	}
+}
+
 func (l *Layer) LineEncodingAt(index int) LineEncoding {
 	return LineEncoding((l.linesEncoding[index>>2] >> ((index & 0x3) << 1)) & 0x3)
 }
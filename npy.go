@@ -0,0 +1,193 @@
+package ppmlib
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// NPYOptions select the frames, layout and layers ExportNPY and ExportNPZ
+// write.
+type NPYOptions struct {
+	FrameRange
+	// Layout of the layer array, NPYLayoutLayers when zero.
+	Layout NPYLayout
+	// Flags overrides the flipnote's AnimationFlags when set. Hidden layers
+	// are exported empty.
+	Flags *AnimationFlags
+}
+
+// ExportNPY writes the layers of the flipnote's frames as a uint8 NumPy
+// array, laid out as opts.Layout describes.
+func (f *PPMFile) ExportNPY(w io.Writer, opts *NPYOptions) error {
+	if opts == nil {
+		opts = &NPYOptions{}
+	}
+
+	shape, data, err := f.npyLayers(opts)
+	if err != nil {
+		return err
+	}
+
+	return writeNPY(w, "|u1", shape, data)
+}
+
+// ExportNPZ writes an uncompressed .npz archive holding the layers as
+// "layers.npy" along with the metadata of the exported frames:
+//
+//	paper_color        uint8 (frames,)     0 black, 1 white
+//	pen_colors         uint8 (frames, 2)   PenColor of layer 1 and layer 2
+//	sound_effect_flags uint8 (frames, 3)   1 where SE1, SE2 or SE3 plays
+//	framerate          float32 ()
+func (f *PPMFile) ExportNPZ(w io.Writer, opts *NPYOptions) error {
+	if opts == nil {
+		opts = &NPYOptions{}
+	}
+
+	shape, data, err := f.npyLayers(opts)
+	if err != nil {
+		return err
+	}
+
+	start, end, _ := f.frameRange(opts.Start, opts.End)
+	count := end - start
+
+	paper := make([]byte, count)
+	pens := make([]byte, count*2)
+	soundEffects := make([]byte, count*3)
+	for i, frame := range f.Frames[start:end] {
+		paper[i] = byte(frame.PaperColor)
+		pens[i*2] = byte(frame.Layer1.PenColor)
+		pens[i*2+1] = byte(frame.Layer2.PenColor)
+
+		if start+i < len(f.SoundEffectFlags) {
+			for track := 0; track < 3; track++ {
+				soundEffects[i*3+track] = f.SoundEffectFlags[start+i] >> track & 1
+			}
+		}
+	}
+
+	framerate := make([]byte, 4)
+	binary.LittleEndian.PutUint32(framerate, math.Float32bits(f.Framerate))
+
+	arrays := []struct {
+		name  string
+		descr string
+		shape []int
+		data  []byte
+	}{
+		{"layers", "|u1", shape, data},
+		{"paper_color", "|u1", []int{count}, paper},
+		{"pen_colors", "|u1", []int{count, 2}, pens},
+		{"sound_effect_flags", "|u1", []int{count, 3}, soundEffects},
+		{"framerate", "<f4", []int{}, framerate},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, array := range arrays {
+		file, err := zw.CreateHeader(&zip.FileHeader{Name: array.name + ".npy", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+
+		if err := writeNPY(file, array.descr, array.shape, array.data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// npyLayers unpacks the layers of the selected frames into one byte per
+// pixel.
+func (f *PPMFile) npyLayers(opts *NPYOptions) ([]int, []byte, error) {
+	start, end, err := f.frameRange(opts.Start, opts.End)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count := end - start
+	flags := f.animationFlags(opts.Flags)
+
+	switch opts.Layout {
+	case NPYLayoutLayers:
+		data := make([]byte, count*2*256*192)
+		for i, frame := range f.Frames[start:end] {
+			if !flags.HideLayer1 {
+				unpackLayer(data[(i*2)*256*192:], frame.Layer1, 1)
+			}
+			if !flags.HideLayer2 {
+				unpackLayer(data[(i*2+1)*256*192:], frame.Layer2, 1)
+			}
+		}
+
+		return []int{count, 2, 192, 256}, data, nil
+	case NPYLayoutMerged:
+		data := make([]byte, count*256*192)
+		for i, frame := range f.Frames[start:end] {
+			dst := data[i*256*192:]
+			if !flags.HideLayer2 {
+				unpackLayer(dst, frame.Layer2, 2)
+			}
+			if !flags.HideLayer1 {
+				unpackLayer(dst, frame.Layer1, 1)
+			}
+		}
+
+		return []int{count, 192, 256}, data, nil
+	}
+
+	return nil, nil, errors.New("invalid layout")
+}
+
+// unpackLayer sets the bytes of dst where the layer is drawn to value.
+func unpackLayer(dst []byte, layer *Layer, value byte) {
+	for i, b := range layer.layerData {
+		for bit := 0; bit < 8; bit++ {
+			if b>>bit&1 != 0 {
+				dst[i*8+bit] = value
+			}
+		}
+	}
+}
+
+// writeNPY writes a version 1.0 .npy file of a C ordered array.
+func writeNPY(w io.Writer, descr string, shape []int, data []byte) error {
+	dims := make([]string, len(shape))
+	for i, dim := range shape {
+		dims[i] = fmt.Sprint(dim)
+	}
+
+	// a tuple of one needs a trailing comma
+	tuple := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		tuple += ","
+	}
+
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, tuple)
+
+	// the magic, version, length and header are padded to a multiple of 64
+	// bytes with spaces and a newline
+	padding := 64 - (10+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	header += strings.Repeat(" ", padding) + "\n"
+
+	prefix := []byte("\x93NUMPY\x01\x00\x00\x00")
+	binary.LittleEndian.PutUint16(prefix[8:], uint16(len(header)))
+
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+
+	return err
+}
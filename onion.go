@@ -0,0 +1,139 @@
+package ppmlib
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+type OnionSkinOptions struct {
+	RenderOptions
+	// PastTint and FutureTint color the lines of the frames before and
+	// after the current one, red and green when nil.
+	PastTint   *color.RGBA
+	FutureTint *color.RGBA
+	// Opacity of the closest neighbouring frames from 0 to 1, 0.5 when nil.
+	// Frames further away fade out linearly.
+	Opacity *float64
+}
+
+var (
+	onionSkinPastTint   = color.RGBA{0xE0, 0x30, 0x30, 0xFF}
+	onionSkinFutureTint = color.RGBA{0x30, 0xA0, 0x30, 0xFF}
+)
+
+// RenderOnionSkin draws frame index of the file over tinted copies of up to
+// before frames before it and after frames after it.
+func RenderOnionSkin(file *PPMFile, index, before, after int, opts *OnionSkinOptions) (*image.RGBA, error) {
+	if opts == nil {
+		opts = &OnionSkinOptions{}
+	}
+
+	if index < 0 || index >= len(file.Frames) {
+		return nil, errors.New("invalid frame index")
+	}
+
+	if before < 0 || after < 0 {
+		return nil, errors.New("invalid onion skin frame count")
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return nil, err
+	}
+
+	pastTint, futureTint := opts.PastTint, opts.FutureTint
+	if pastTint == nil {
+		pastTint = &onionSkinPastTint
+	}
+	if futureTint == nil {
+		futureTint = &onionSkinFutureTint
+	}
+
+	opacity := 0.5
+	if opts.Opacity != nil {
+		opacity = *opts.Opacity
+	}
+
+	if opacity < 0 || opacity > 1 {
+		return nil, errors.New("invalid opacity")
+	}
+
+	renderer := file.renderer(scale, opts.Palette, opts.Flags)
+	renderer.TransparentPaper = true
+
+	frame := file.Frames[index]
+	paper := renderer.palette().White
+	if frame.PaperColor == PaperColorBlack {
+		paper = renderer.palette().Black
+	}
+
+	dst := image.NewRGBA(renderer.Bounds())
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = paper.R, paper.G, paper.B, paper.A
+	}
+
+	// the furthest frames are drawn first, so closer ones end up on top
+	lines := image.NewRGBA(renderer.Bounds())
+	for distance := maxInt(before, after); distance > 0; distance-- {
+		neighbours := []struct {
+			index int
+			count int
+			tint  *color.RGBA
+		}{
+			{index - distance, before, pastTint},
+			{index + distance, after, futureTint},
+		}
+
+		for _, n := range neighbours {
+			if distance > n.count || n.index < 0 || n.index >= len(file.Frames) {
+				continue
+			}
+
+			if _, err := renderer.RenderRGBA(file.Frames[n.index], lines); err != nil {
+				return nil, err
+			}
+
+			alpha := opacity * float64(n.count-distance+1) / float64(n.count)
+			blendMask(dst, lines, n.tint, alpha)
+		}
+	}
+
+	if _, err := renderer.RenderRGBA(frame, lines); err != nil {
+		return nil, err
+	}
+	blendMask(dst, lines, nil, 1)
+
+	return dst, nil
+}
+
+// blendMask blends tint over dst wherever mask is opaque. A nil tint uses
+// the mask's own colors.
+func blendMask(dst, mask *image.RGBA, tint *color.RGBA, alpha float64) {
+	for i := 0; i < len(dst.Pix); i += 4 {
+		if mask.Pix[i+3] == 0 {
+			continue
+		}
+
+		src := color.RGBA{mask.Pix[i], mask.Pix[i+1], mask.Pix[i+2], mask.Pix[i+3]}
+		if tint != nil {
+			src = *tint
+		}
+
+		dst.Pix[i] = blendByte(dst.Pix[i], src.R, alpha)
+		dst.Pix[i+1] = blendByte(dst.Pix[i+1], src.G, alpha)
+		dst.Pix[i+2] = blendByte(dst.Pix[i+2], src.B, alpha)
+	}
+}
+
+func blendByte(dst, src byte, alpha float64) byte {
+	return clampByte(float64(dst)*(1-alpha) + float64(src)*alpha)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
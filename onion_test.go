@@ -0,0 +1,54 @@
+package ppmlib
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRenderOnionSkin(t *testing.T) {
+	file := testFlipnote(3)
+	opaque, invisible := 1.0, 0.0
+
+	for _, test := range []struct {
+		name string
+		opts *OnionSkinOptions
+		want color.RGBA
+	}{
+		{"default tint", nil, color.RGBA{0x70, 0x18, 0x18, 0xFF}},
+		{"custom tint", &OnionSkinOptions{PastTint: &color.RGBA{0, 0, 200, 255}}, color.RGBA{0, 0, 100, 255}},
+		// opaque black used to be indistinguishable from an unset tint
+		{"black tint", &OnionSkinOptions{PastTint: &color.RGBA{0, 0, 0, 255}, Opacity: &opaque}, color.RGBA{0, 0, 0, 255}},
+		// a zero opacity leaves the neighbouring frames out, showing the paper
+		{"zero opacity", &OnionSkinOptions{Opacity: &invisible}, PaletteDefault.Black},
+	} {
+		img, err := RenderOnionSkin(file, 1, 1, 1, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 0,10 is only drawn in frame 0, 1,10 in the current frame as well
+		if got := img.RGBAAt(0, 10); got != test.want {
+			t.Errorf("%s: past frame pixel is %v, want %v", test.name, got, test.want)
+		}
+		if got := img.RGBAAt(1, 10); got != PaletteDefault.White {
+			t.Errorf("%s: current frame pixel is %v, want white", test.name, got)
+		}
+	}
+
+	tooOpaque, negative := 1.5, -0.1
+	for _, test := range []struct {
+		name                 string
+		index, before, after int
+		opts                 *OnionSkinOptions
+	}{
+		{"index out of range", 3, 1, 1, nil},
+		{"negative count", 1, -1, 1, nil},
+		{"negative scale", 1, 1, 1, &OnionSkinOptions{RenderOptions: RenderOptions{Scale: -1}}},
+		{"opacity above 1", 1, 1, 1, &OnionSkinOptions{Opacity: &tooOpaque}},
+		{"negative opacity", 1, 1, 1, &OnionSkinOptions{Opacity: &negative}},
+	} {
+		if _, err := RenderOnionSkin(file, test.index, test.before, test.after, test.opts); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
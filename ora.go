@@ -0,0 +1,181 @@
+package ppmlib
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type oraImage struct {
+	XMLName xml.Name   `xml:"image"`
+	Version string     `xml:"version,attr"`
+	Width   int        `xml:"w,attr"`
+	Height  int        `xml:"h,attr"`
+	Layers  []oraLayer `xml:"stack>layer"`
+}
+
+type oraLayer struct {
+	Name       string `xml:"name,attr"`
+	Src        string `xml:"src,attr"`
+	X          int    `xml:"x,attr"`
+	Y          int    `xml:"y,attr"`
+	Opacity    string `xml:"opacity,attr"`
+	Visibility string `xml:"visibility,attr"`
+}
+
+type ORAOptions struct {
+	RenderOptions
+}
+
+// ExportORA writes the frame as an OpenRaster file with the paper, layer 2
+// and layer 1 as separate layers. Layers the flipnote's AnimationFlags hide
+// are hidden.
+func (f *Frame) ExportORA(w io.Writer, opts *ORAOptions) error {
+	if opts == nil {
+		opts = &ORAOptions{}
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	return f.writeORA(w, f.file.renderer(scale, opts.Palette, opts.Flags))
+}
+
+// ExportORASequence writes every frame to dir as an OpenRaster file named
+// after the flipnote's current filename and the frame index, e.g.
+// "<filename>_0001.ora". Layers the AnimationFlags hide are hidden.
+func (f *PPMFile) ExportORASequence(dir string, opts *ORAOptions) error {
+	if opts == nil {
+		opts = &ORAOptions{}
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+
+	filename := ""
+	if f.CurrentFilename != nil {
+		filename = f.CurrentFilename.String()
+	}
+
+	for i, frame := range f.Frames {
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("%s_%04d.ora", filename, i)))
+		if err != nil {
+			return err
+		}
+
+		err = frame.writeORA(file, renderer)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *Frame) writeORA(w io.Writer, renderer *Renderer) error {
+	bounds := renderer.Bounds()
+
+	paper := image.NewPaletted(bounds, renderer.palette().ColorPalette())
+	paperIndex, _, _ := frameColorIndices(f)
+	for i := range paper.Pix {
+		paper.Pix[i] = paperIndex
+	}
+
+	layer1, err := renderer.RenderLayer(f, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	layer2, err := renderer.RenderLayer(f, 2, nil)
+	if err != nil {
+		return err
+	}
+
+	merged, err := renderer.RenderRGBA(f, nil)
+	if err != nil {
+		return err
+	}
+
+	visibility := func(hidden bool) string {
+		if hidden {
+			return "hidden"
+		}
+
+		return "visible"
+	}
+
+	// the first layer of the stack is the topmost one
+	stack := oraImage{
+		Version: "0.0.5",
+		Width:   bounds.Dx(),
+		Height:  bounds.Dy(),
+		Layers: []oraLayer{
+			{Name: "Layer 1", Src: "data/layer1.png", Opacity: "1.0", Visibility: visibility(renderer.HideLayer1)},
+			{Name: "Layer 2", Src: "data/layer2.png", Opacity: "1.0", Visibility: visibility(renderer.HideLayer2)},
+			{Name: "Paper", Src: "data/paper.png", Opacity: "1.0", Visibility: "visible"},
+		},
+	}
+
+	stackXML, err := xml.MarshalIndent(stack, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	// the mimetype has to be the first entry, stored uncompressed
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := mimetype.Write([]byte("image/openraster")); err != nil {
+		return err
+	}
+
+	stackFile, err := zw.Create("stack.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := stackFile.Write(append([]byte(xml.Header), stackXML...)); err != nil {
+		return err
+	}
+
+	images := []struct {
+		name string
+		img  image.Image
+	}{
+		{"data/layer1.png", layer1},
+		{"data/layer2.png", layer2},
+		{"data/paper.png", paper},
+		{"mergedimage.png", merged},
+		{"Thumbnails/thumbnail.png", merged},
+	}
+
+	for _, entry := range images {
+		file, err := zw.Create(entry.name)
+		if err != nil {
+			return err
+		}
+
+		if err := png.Encode(file, entry.img); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,135 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPcm   = 0x1
+	wavFormatFloat = 0x3
+)
+
+// EncodePcm writes mono 16-bit samples in the given format.
+func EncodePcm(writer io.Writer, pcm []int16, sampleRate int, format AudioFormat) error {
+	if sampleRate <= 0 {
+		return errors.New("invalid sample rate")
+	}
+
+	switch format {
+	case AudioFormatWAV:
+		return encodeWav(writer, pcm, sampleRate)
+	case AudioFormatWAVFloat32:
+		return encodeWavFloat32(writer, pcm, sampleRate)
+	case AudioFormatPCM:
+		return binary.Write(writer, binary.LittleEndian, pcm)
+	case AudioFormatAIFF:
+		return encodeAiff(writer, pcm, sampleRate)
+	case AudioFormatFLAC:
+		return encodeFlac(writer, pcm, sampleRate)
+	}
+
+	return errors.New("invalid audio format")
+}
+
+func encodeWav(writer io.Writer, pcm []int16, sampleRate int) error {
+	dataSize := len(pcm) * 2
+
+	out := &bytes.Buffer{}
+	out.WriteString("RIFF")
+	binary.Write(out, binary.LittleEndian, uint32(4+(8+16)+(8+dataSize)))
+	out.WriteString("WAVE")
+
+	out.WriteString("fmt ")
+	binary.Write(out, binary.LittleEndian, uint32(16))
+	binary.Write(out, binary.LittleEndian, uint16(wavFormatPcm))
+	binary.Write(out, binary.LittleEndian, uint16(1))
+	binary.Write(out, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(out, binary.LittleEndian, uint32(sampleRate*2))
+	binary.Write(out, binary.LittleEndian, uint16(2))
+	binary.Write(out, binary.LittleEndian, uint16(16))
+
+	out.WriteString("data")
+	binary.Write(out, binary.LittleEndian, uint32(dataSize))
+	binary.Write(out, binary.LittleEndian, pcm)
+
+	_, err := writer.Write(out.Bytes())
+	return err
+}
+
+func encodeWavFloat32(writer io.Writer, pcm []int16, sampleRate int) error {
+	dataSize := len(pcm) * 4
+
+	out := &bytes.Buffer{}
+	out.WriteString("RIFF")
+	binary.Write(out, binary.LittleEndian, uint32(4+(8+18)+(8+4)+(8+dataSize)))
+	out.WriteString("WAVE")
+
+	out.WriteString("fmt ")
+	binary.Write(out, binary.LittleEndian, uint32(18))
+	binary.Write(out, binary.LittleEndian, uint16(wavFormatFloat))
+	binary.Write(out, binary.LittleEndian, uint16(1))
+	binary.Write(out, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(out, binary.LittleEndian, uint32(sampleRate*4))
+	binary.Write(out, binary.LittleEndian, uint16(4))
+	binary.Write(out, binary.LittleEndian, uint16(32))
+	binary.Write(out, binary.LittleEndian, uint16(0))
+
+	out.WriteString("fact")
+	binary.Write(out, binary.LittleEndian, uint32(4))
+	binary.Write(out, binary.LittleEndian, uint32(len(pcm)))
+
+	out.WriteString("data")
+	binary.Write(out, binary.LittleEndian, uint32(dataSize))
+	for _, sample := range pcm {
+		binary.Write(out, binary.LittleEndian, float32(sample)/32768)
+	}
+
+	_, err := writer.Write(out.Bytes())
+	return err
+}
+
+func encodeAiff(writer io.Writer, pcm []int16, sampleRate int) error {
+	dataSize := len(pcm) * 2
+
+	out := &bytes.Buffer{}
+	out.WriteString("FORM")
+	binary.Write(out, binary.BigEndian, uint32(4+(8+18)+(8+8+dataSize)))
+	out.WriteString("AIFF")
+
+	out.WriteString("COMM")
+	binary.Write(out, binary.BigEndian, uint32(18))
+	binary.Write(out, binary.BigEndian, uint16(1))
+	binary.Write(out, binary.BigEndian, uint32(len(pcm)))
+	binary.Write(out, binary.BigEndian, uint16(16))
+	out.Write(float80(sampleRate))
+
+	out.WriteString("SSND")
+	binary.Write(out, binary.BigEndian, uint32(8+dataSize))
+	binary.Write(out, binary.BigEndian, uint32(0))
+	binary.Write(out, binary.BigEndian, uint32(0))
+	binary.Write(out, binary.BigEndian, pcm)
+
+	_, err := writer.Write(out.Bytes())
+	return err
+}
+
+// float80 encodes a positive integer as the 80-bit IEEE extended float used
+// by the AIFF COMM chunk.
+func float80(value int) []byte {
+	res := make([]byte, 10)
+	if value <= 0 {
+		return res
+	}
+
+	exponent := int(math.Floor(math.Log2(float64(value))))
+	mantissa := uint64(value) << (63 - exponent)
+
+	binary.BigEndian.PutUint16(res[0:2], uint16(16383+exponent))
+	binary.BigEndian.PutUint64(res[2:10], mantissa)
+
+	return res
+}
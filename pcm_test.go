@@ -0,0 +1,205 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// testSignals are the sample streams the encoders are checked against, from
+// empty up to more than one flac block, including both 16-bit extremes.
+func testSignals() map[string][]int16 {
+	sine := make([]int16, 10000)
+	for i := range sine {
+		sine[i] = int16(12000 * math.Sin(2*math.Pi*440*float64(i)/32768))
+	}
+
+	noise := make([]int16, 5000)
+	seed := uint32(1)
+	for i := range noise {
+		seed = seed*1664525 + 1013904223
+		noise[i] = int16(seed >> 16)
+	}
+
+	return map[string][]int16{
+		"empty":    {},
+		"single":   {1234},
+		"short":    {0, 100, -100, 32767, -32768, 5, 6, 7},
+		"extremes": {32767, -32768, 32767, -32768, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, -1},
+		"constant": bytesToSamples(bytes.Repeat([]byte{0x10, 0x20}, 4500)),
+		"sine":     sine,
+		"noise":    noise,
+	}
+}
+
+func bytesToSamples(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, samples)
+	return samples
+}
+
+// decodeTestWav reads a mono wav file written by EncodePcm, returning its
+// format tag, bits per sample, sample rate and samples.
+func decodeTestWav(t *testing.T, data []byte) (int, int, int, []int16) {
+	t.Helper()
+
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		t.Fatal("missing RIFF header")
+	}
+	if size := int(binary.LittleEndian.Uint32(data[4:8])); size != len(data)-8 {
+		t.Fatalf("RIFF size is %d, file has %d bytes after it", size, len(data)-8)
+	}
+
+	var format, bits, rate int
+	var samples []int16
+	for offset := 12; offset < len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		chunk := data[offset+8 : offset+8+size]
+		offset += 8 + size
+
+		switch id {
+		case "fmt ":
+			format = int(binary.LittleEndian.Uint16(chunk[0:2]))
+			if channels := binary.LittleEndian.Uint16(chunk[2:4]); channels != 1 {
+				t.Fatalf("%d channels, want 1", channels)
+			}
+			rate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:16]))
+
+			blockAlign := int(binary.LittleEndian.Uint16(chunk[12:14]))
+			if blockAlign != bits/8 || int(binary.LittleEndian.Uint32(chunk[8:12])) != rate*blockAlign {
+				t.Fatal("byte rate or block align does not match the sample format")
+			}
+		case "data":
+			switch bits {
+			case 16:
+				samples = bytesToSamples(chunk)
+			case 32:
+				samples = make([]int16, len(chunk)/4)
+				for i := range samples {
+					value := math.Float32frombits(binary.LittleEndian.Uint32(chunk[i*4:]))
+					samples[i] = int16(value * 32768)
+				}
+			}
+		}
+	}
+
+	return format, bits, rate, samples
+}
+
+// decodeTestAiff reads a mono 16-bit AIFF file written by EncodePcm,
+// returning its sample rate and samples.
+func decodeTestAiff(t *testing.T, data []byte) (int, []int16) {
+	t.Helper()
+
+	if string(data[0:4]) != "FORM" || string(data[8:12]) != "AIFF" {
+		t.Fatal("missing FORM header")
+	}
+	if size := int(binary.BigEndian.Uint32(data[4:8])); size != len(data)-8 {
+		t.Fatalf("FORM size is %d, file has %d bytes after it", size, len(data)-8)
+	}
+
+	var rate, frames int
+	var samples []int16
+	for offset := 12; offset < len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.BigEndian.Uint32(data[offset+4 : offset+8]))
+		chunk := data[offset+8 : offset+8+size]
+		offset += 8 + size + size%2
+
+		switch id {
+		case "COMM":
+			if channels := binary.BigEndian.Uint16(chunk[0:2]); channels != 1 {
+				t.Fatalf("%d channels, want 1", channels)
+			}
+			frames = int(binary.BigEndian.Uint32(chunk[2:6]))
+			if bits := binary.BigEndian.Uint16(chunk[6:8]); bits != 16 {
+				t.Fatalf("%d bits per sample, want 16", bits)
+			}
+
+			exponent := int(binary.BigEndian.Uint16(chunk[8:10])&0x7FFF) - 16383
+			mantissa := binary.BigEndian.Uint64(chunk[10:18])
+			rate = int(math.Ldexp(float64(mantissa), exponent-63))
+		case "SSND":
+			samples = make([]int16, (size-8)/2)
+			binary.Read(bytes.NewReader(chunk[8:]), binary.BigEndian, samples)
+		}
+	}
+
+	if frames != len(samples) {
+		t.Fatalf("COMM has %d frames, SSND %d samples", frames, len(samples))
+	}
+
+	return rate, samples
+}
+
+func TestEncodePcmRoundTrip(t *testing.T) {
+	for name, pcm := range testSignals() {
+		for _, rate := range []int{8192, 32768, 44100, 48000} {
+			for _, format := range []AudioFormat{AudioFormatWAV, AudioFormatWAVFloat32, AudioFormatPCM, AudioFormatAIFF} {
+				buf := &bytes.Buffer{}
+				if err := EncodePcm(buf, pcm, rate, format); err != nil {
+					t.Fatalf("%s %d %s: %v", name, rate, format, err)
+				}
+
+				var decoded []int16
+				decodedRate := rate
+
+				switch format {
+				case AudioFormatWAV, AudioFormatWAVFloat32:
+					wantFormat, wantBits := wavFormatPcm, 16
+					if format == AudioFormatWAVFloat32 {
+						wantFormat, wantBits = wavFormatFloat, 32
+					}
+
+					var wavFormat, bits int
+					wavFormat, bits, decodedRate, decoded = decodeTestWav(t, buf.Bytes())
+					if wavFormat != wantFormat || bits != wantBits {
+						t.Errorf("%s %d %s: format %d with %d bits, want %d with %d", name, rate, format, wavFormat, bits, wantFormat, wantBits)
+					}
+				case AudioFormatPCM:
+					if buf.Len() != len(pcm)*2 {
+						t.Errorf("%s %d %s: %d bytes, want %d", name, rate, format, buf.Len(), len(pcm)*2)
+					}
+					decoded = bytesToSamples(buf.Bytes())
+				case AudioFormatAIFF:
+					decodedRate, decoded = decodeTestAiff(t, buf.Bytes())
+				}
+
+				if decodedRate != rate {
+					t.Errorf("%s %d %s: decoded a sample rate of %d", name, rate, format, decodedRate)
+				}
+
+				if !equalSamples(decoded, pcm) {
+					t.Errorf("%s %d %s: samples differ after decoding", name, rate, format)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodePcmRejectsInvalidInput(t *testing.T) {
+	if err := EncodePcm(&bytes.Buffer{}, []int16{1}, 0, AudioFormatWAV); err == nil {
+		t.Error("a sample rate of zero was accepted")
+	}
+
+	if err := EncodePcm(&bytes.Buffer{}, []int16{1}, 8192, AudioFormat(-1)); err == nil {
+		t.Error("an invalid format was accepted")
+	}
+}
+
+func equalSamples(a []int16, b []int16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
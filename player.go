@@ -0,0 +1,279 @@
+package ppmlib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// HTMLPlayerOptions configure ExportHTMLPlayer. The canvas is shown at twice
+// its size when Scale is zero.
+type HTMLPlayerOptions struct {
+	RenderOptions
+	// SampleRate of the embedded audio, 32768 when zero.
+	SampleRate int
+	// Title of the page, the flipnote's current filename when empty.
+	Title string
+}
+
+type htmlPlayerData struct {
+	Title     string
+	Author    string
+	Timestamp string
+	Width     int
+	Height    int
+
+	Frames     template.JS
+	Audio      template.URL
+	FrameCount int
+	Framerate  float32
+	Loop       bool
+	HideLayer1 bool
+	HideLayer2 bool
+	Palette    []string
+}
+
+// ExportHTMLPlayer writes a single HTML page that plays the flipnote. Frames
+// are embedded as gzipped 1-bit layer data and the master mix, if there is
+// any sound, as a base64 wav. A small script draws them to a canvas with
+// play, pause and seek.
+func (f *PPMFile) ExportHTMLPlayer(w io.Writer, opts *HTMLPlayerOptions) error {
+	if opts == nil {
+		opts = &HTMLPlayerOptions{}
+	}
+
+	if len(f.Frames) == 0 {
+		return errors.New("flipnote has no frames")
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	scale := opts.Scale
+	if scale == 0 {
+		scale = 2
+	}
+	if scale < 0 {
+		return errors.New("invalid scale")
+	}
+
+	sampleRate := opts.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 32768
+	}
+
+	palette := opts.Palette
+	if palette == nil {
+		palette = &PaletteDefault
+	}
+
+	flags := f.animationFlags(opts.Flags)
+
+	data := htmlPlayerData{
+		Title:      opts.Title,
+		Width:      256 * scale,
+		Height:     192 * scale,
+		FrameCount: len(f.Frames),
+		Framerate:  f.Framerate,
+		Loop:       flags.Loop,
+		HideLayer1: flags.HideLayer1,
+		HideLayer2: flags.HideLayer2,
+	}
+
+	if f.CurrentFilename != nil && data.Title == "" {
+		data.Title = f.CurrentFilename.String()
+	}
+	if f.CurrentAuthor != nil {
+		data.Author = strings.TrimRight(f.CurrentAuthor.Name, "\x00")
+	}
+	if f.Timestamp != nil {
+		data.Timestamp = f.Timestamp.String()
+	}
+
+	for _, c := range palette.colors()[:4] {
+		data.Palette = append(data.Palette, svgColor(c))
+	}
+
+	// every frame is the palette indices of its paper and pens, followed by
+	// both layers as 32 bytes per row, least significant bit first
+	frames := &bytes.Buffer{}
+	zw := gzip.NewWriter(frames)
+	for _, frame := range f.Frames {
+		paper, pen1, pen2 := frameColorIndices(frame)
+		zw.Write([]byte{paper, pen1, pen2})
+		zw.Write(frame.Layer1.layerData)
+		zw.Write(frame.Layer2.layerData)
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	// base64 cannot end the string it is quoted in
+	data.Frames = template.JS(`"` + base64.StdEncoding.EncodeToString(frames.Bytes()) + `"`)
+
+	// flipnotes without sound still carry an empty audio section
+	decoder := NewAudioDecoder(f)
+	if decoder.hasTrack(Master) {
+		pcm, err := decoder.GetAudioMasterPcm(sampleRate)
+		if err != nil {
+			return err
+		}
+
+		wav := &bytes.Buffer{}
+		if err := encodeWav(wav, pcm, sampleRate); err != nil {
+			return err
+		}
+
+		data.Audio = template.URL("data:audio/wav;base64," + base64.StdEncoding.EncodeToString(wav.Bytes()))
+	}
+
+	return htmlPlayerTemplate.Execute(w, data)
+}
+
+var htmlPlayerTemplate = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; background: #eee; margin: 2em; }
+.player { display: inline-block; background: #fff; padding: 1em; border-radius: 4px; }
+canvas { display: block; image-rendering: pixelated; width: {{.Width}}px; height: {{.Height}}px; }
+.controls { display: flex; align-items: center; gap: 0.5em; margin-top: 0.5em; }
+.controls input { flex: 1; }
+.meta { color: #555; font-size: 0.9em; margin-top: 0.5em; }
+</style>
+</head>
+<body>
+<div class="player">
+<canvas width="256" height="192"></canvas>
+<div class="controls">
+<button id="play">Play</button>
+<input id="seek" type="range" min="0" max="{{.FrameCount}}" value="0" step="1">
+<span id="counter"></span>
+</div>
+<div class="meta">
+<div>{{.Title}}</div>
+<div>{{.Author}}</div>
+<div>{{.Timestamp}}</div>
+</div>
+{{if .Audio}}<audio id="audio" src="{{.Audio}}" preload="auto"></audio>{{end}}
+</div>
+<script>
+(async function () {
+  const frameCount = {{.FrameCount}};
+  const framerate = {{.Framerate}};
+  const loop = {{.Loop}};
+  const hideLayer1 = {{.HideLayer1}};
+  const hideLayer2 = {{.HideLayer2}};
+  const palette = {{.Palette}}.map(function (hex) {
+    return [1, 3, 5].map(function (i) { return parseInt(hex.substr(i, 2), 16); });
+  });
+
+  const packed = await fetch("data:application/octet-stream;base64," + {{.Frames}});
+  const frames = new Uint8Array(await new Response(packed.body.pipeThrough(new DecompressionStream("gzip"))).arrayBuffer());
+  const frameSize = 3 + 2 * 32 * 192;
+
+  const canvas = document.querySelector("canvas");
+  const context = canvas.getContext("2d");
+  const image = context.createImageData(256, 192);
+  const audio = document.getElementById("audio");
+  const play = document.getElementById("play");
+  const seek = document.getElementById("seek");
+  const counter = document.getElementById("counter");
+  seek.max = frameCount - 1;
+
+  let current = 0;
+  let playing = false;
+  let startTime = 0;
+
+  function draw(index) {
+    const offset = index * frameSize;
+    const inks = [frames[offset], frames[offset + 1], frames[offset + 2]];
+    const layer1 = offset + 3;
+    const layer2 = layer1 + 32 * 192;
+
+    for (let p = 0; p < 256 * 192; p++) {
+      const bit = 1 << (p & 7);
+      let ink = inks[0];
+      if (!hideLayer1 && frames[layer1 + (p >> 3)] & bit) {
+        ink = inks[1];
+      } else if (!hideLayer2 && frames[layer2 + (p >> 3)] & bit) {
+        ink = inks[2];
+      }
+
+      const color = palette[ink];
+      image.data[p * 4] = color[0];
+      image.data[p * 4 + 1] = color[1];
+      image.data[p * 4 + 2] = color[2];
+      image.data[p * 4 + 3] = 255;
+    }
+
+    context.putImageData(image, 0, 0);
+    current = index;
+    seek.value = index;
+    counter.textContent = (index + 1) + " / " + frameCount;
+  }
+
+  function seekTo(index) {
+    startTime = performance.now() - index / framerate * 1000;
+    if (audio) {
+      audio.currentTime = index / framerate;
+    }
+    draw(index);
+  }
+
+  function setPlaying(value) {
+    playing = value;
+    play.textContent = playing ? "Pause" : "Play";
+
+    if (playing) {
+      if (current === frameCount - 1 && !loop) {
+        current = 0;
+      }
+      seekTo(current);
+      if (audio) {
+        audio.play();
+      }
+      requestAnimationFrame(tick);
+    } else if (audio) {
+      audio.pause();
+    }
+  }
+
+  function tick(now) {
+    if (!playing) {
+      return;
+    }
+
+    let index = Math.floor((now - startTime) / 1000 * framerate);
+    if (index >= frameCount) {
+      if (!loop) {
+        draw(frameCount - 1);
+        setPlaying(false);
+        return;
+      }
+
+      index %= frameCount;
+      seekTo(index);
+    } else if (index !== current) {
+      draw(index);
+    }
+
+    requestAnimationFrame(tick);
+  }
+
+  play.addEventListener("click", function () { setPlaying(!playing); });
+  seek.addEventListener("input", function () { seekTo(parseInt(seek.value, 10)); });
+
+  draw(0);
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,76 @@
+package ppmlib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"regexp"
+	"testing"
+)
+
+var playerFrames = regexp.MustCompile(`"data:application/octet-stream;base64," \+ "([A-Za-z0-9+/=]*)"`)
+
+func TestExportHTMLPlayer(t *testing.T) {
+	silent := testFlipnote(12)
+	for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+		if err := silent.Audio.setTrackData(track, []byte{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		file     *PPMFile
+		hasAudio bool
+	}{
+		{"sound", testFlipnote(12), true},
+		{"silent", silent, false},
+	} {
+		out := &bytes.Buffer{}
+		if err := test.file.ExportHTMLPlayer(out, nil); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if hasAudio := bytes.Contains(out.Bytes(), []byte("data:audio/wav;base64,")); hasAudio != test.hasAudio {
+			t.Errorf("%s: audio present is %v, want %v", test.name, hasAudio, test.hasAudio)
+		}
+
+		match := playerFrames.FindSubmatch(out.Bytes())
+		if match == nil {
+			t.Fatalf("%s: no frame data", test.name)
+		}
+
+		packed, err := base64.StdEncoding.DecodeString(string(match[1]))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(packed))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		frames, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		const frameSize = 3 + 2*32*192
+		if len(frames) != len(test.file.Frames)*frameSize {
+			t.Fatalf("%s: frame data is %d bytes, want %d", test.name, len(frames), len(test.file.Frames)*frameSize)
+		}
+
+		for i, frame := range test.file.Frames {
+			data := frames[i*frameSize : (i+1)*frameSize]
+			paper, pen1, pen2 := frameColorIndices(frame)
+
+			if !bytes.Equal(data[:3], []byte{paper, pen1, pen2}) {
+				t.Errorf("%s, frame %d: colors are %v, want %v", test.name, i, data[:3], []byte{paper, pen1, pen2})
+			}
+			if !bytes.Equal(data[3:3+32*192], frame.Layer1.layerData) || !bytes.Equal(data[3+32*192:], frame.Layer2.layerData) {
+				t.Errorf("%s, frame %d: layers differ", test.name, i)
+			}
+		}
+	}
+}
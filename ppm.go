@@ -44,7 +44,7 @@ type PPMFile struct {
 	Frames       []*Frame `json:"-"`
 	FramesParsed uint16
 
-	AnimationFlags uint16
+	AnimationFlags AnimationFlags
 
 	Audio *Audio `json:"-"`
 
@@ -142,17 +142,19 @@ func Parse(data []byte) (*PPMFile, error) {
 
 	buffer.SeekByte(4, true)
 
-	file.AnimationFlags = buffer.ReadU16LENext(1)[0]
+	file.AnimationFlags = NewAnimationFlags(buffer.ReadU16LENext(1)[0])
 
 	oCnt := file.FrameOffsetTableSize/4 - 1
 	animationOffsets := buffer.ReadU32LENext(int64(oCnt + 1))
 
-	go file.ParseFrames(data, animationOffsets)
-
-	if file.SoundDataSize == 0 {
-		return file, nil
+	if len(animationOffsets) != len(file.Frames) {
+		return nil, errors.New("frame offset table does not match the frame count")
 	}
 
+	file.ParseFrames(data, animationOffsets)
+
+	// the sound effect flags and sound header, which holds the framerate,
+	// are present even when there is no sound data
 	offset := 0x6A0 + int64(file.AnimationDataSize)
 	buffer.SeekByte(offset, false)
 
@@ -204,46 +206,25 @@ func Parse(data []byte) (*PPMFile, error) {
 		return nil, errors.New(fmt.Sprintf("unexpected data (%d) after signature", buffer.ByteCapacity()-buffer.ByteOffset()))
 	}
 
-	//Block until all frames have been processed
-	for {
-		if file.FramesParsed >= uint16(len(file.Frames)-1) {
-			break
-		}
-
-		frames := make([]uint16, 0)
-		for i := uint16(0); i < uint16(len(file.Frames)); i++ {
-			if file.Frames[i] == nil {
-				frames = append(frames, i+1)
-			}
-		}
-		if len(frames) == 0 {
-			break
-		}
-	}
-
-	for i := uint16(1); i < uint16(len(file.Frames)); i++ {
-		go file.Frames[i].Overwrite(file.Frames[i-1])
-	}
-
 	return file, nil
 }
 
+// ParseFrames reads the frame at every offset of the animation data, then
+// applies each diff frame to the finished frame before it. Frames depend on
+// each other in order, so they are reconstructed one after another.
 func (file *PPMFile) ParseFrames(data []byte, offsets []uint32) {
-	perGor := uint16(25)
-	for i := uint16(0); i < uint16(len(offsets)); i += perGor {
-		go func(i uint16) {
-			for j := uint16(0); j < perGor; j++ {
-				frame := i + j
-				if frame >= uint16(len(offsets)) {
-					break
-				}
-
-				frameOffset := 0x06A0 + 8 + int64(file.FrameOffsetTableSize) + int64(offsets[frame])
-				frameEndset := frameOffset + (int64(file.AnimationDataSize) - int64(offsets[frame]))
-				file.Frames[frame] = ReadFrame(crunch.NewBuffer(data[frameOffset:frameEndset]))
-				file.FramesParsed++
-			}
-		}(i)
+	for frame, offset := range offsets {
+		frameOffset := 0x06A0 + 8 + int64(file.FrameOffsetTableSize) + int64(offset)
+		frameEndset := frameOffset + (int64(file.AnimationDataSize) - int64(offset))
+
+		parsed := ReadFrame(crunch.NewBuffer(data[frameOffset:frameEndset]))
+		parsed.file = file
+		file.Frames[frame] = parsed
+		file.FramesParsed++
+	}
+
+	for i := 1; i < len(file.Frames); i++ {
+		file.Frames[i].Overwrite(file.Frames[i-1])
 	}
 }
 
@@ -332,8 +313,8 @@ func CreateFile(author *Author, frames []*Frame, audio []byte) (*PPMFile, error)
 		byteRootFileFragment[i] = byte(conv1 + conv2)
 	}
 
-	copy(file.RootFileFragment[:], byteRootFileFragment)
-	jan, err := time.Parse("2006-02-01", "2000-01-01")
+	file.RootFileFragment = byteRootFileFragment
+	jan, err := time.Parse("2006-01-02", "2000-01-01")
 	if err != nil {
 		return nil, err
 	}
@@ -341,12 +322,13 @@ func CreateFile(author *Author, frames []*Frame, audio []byte) (*PPMFile, error)
 	file.ThumbnailFrameIndex = 0
 
 	animationDataSize := uint32(8 + 4*len(frames))
-	file.AnimationFlags = 0x43
+	file.AnimationFlags = NewAnimationFlags(0x43)
 	file.FrameOffsetTableSize = uint16(len(frames) * 4)
 
 	file.Frames = make([]*Frame, len(frames))
 	for i, frame := range frames {
 		file.Frames[i] = frame
+		frame.file = file
 
 		animationDataSize += uint32(len(frame.Bytes()))
 	}
@@ -385,8 +367,8 @@ func (f *PPMFile) Save(path string) error {
 
 	binary.Write(file, binary.LittleEndian, magic)
 	binary.Write(file, binary.LittleEndian, f.AnimationDataSize)
-	binary.Write(file, binary.LittleEndian, f.SoundDataSize)
-	binary.Write(file, binary.LittleEndian, f.FrameCount)
+	binary.Write(file, binary.LittleEndian, uint32(len(f.Audio.Data.RawBGM)+len(f.Audio.Data.RawSE1)+len(f.Audio.Data.RawSE2)+len(f.Audio.Data.RawSE3)))
+	binary.Write(file, binary.LittleEndian, uint16(len(f.Frames)-1))
 	binary.Write(file, binary.LittleEndian, uint16(0x24))
 	if f.Locked {
 		binary.Write(file, binary.LittleEndian, uint16(1))
@@ -406,15 +388,18 @@ func (f *PPMFile) Save(path string) error {
 	parentAuthor := utils.WriteUTF16String(string(goParentAuthor))
 	currentAuthor := utils.WriteUTF16String(string(goCurrentAuthor))
 
-	for len(rootAuthor) != 11 {
+	for len(rootAuthor) < 22 {
 		rootAuthor = append(rootAuthor, '\000')
 	}
-	for len(parentAuthor) != 11 {
+	rootAuthor = rootAuthor[:22]
+	for len(parentAuthor) < 22 {
 		parentAuthor = append(parentAuthor, '\000')
 	}
-	for len(currentAuthor) != 11 {
+	parentAuthor = parentAuthor[:22]
+	for len(currentAuthor) < 22 {
 		currentAuthor = append(currentAuthor, '\000')
 	}
+	currentAuthor = currentAuthor[:22]
 	binary.Write(file, binary.LittleEndian, rootAuthor)
 	binary.Write(file, binary.LittleEndian, parentAuthor)
 	binary.Write(file, binary.LittleEndian, currentAuthor)
@@ -431,7 +416,7 @@ func (f *PPMFile) Save(path string) error {
 
 	binary.Write(file, binary.LittleEndian, f.FrameOffsetTableSize)
 	binary.Write(file, binary.LittleEndian, uint32(0))
-	binary.Write(file, binary.LittleEndian, f.AnimationFlags)
+	binary.Write(file, binary.LittleEndian, f.AnimationFlags.Value())
 
 	lst := make([][]byte, 0)
 	offset := uint32(0)
@@ -467,8 +452,8 @@ func (f *PPMFile) Save(path string) error {
 	binary.Write(file, binary.LittleEndian, uint32(len(f.Audio.Data.RawSE2))) //SE2
 	binary.Write(file, binary.LittleEndian, uint32(len(f.Audio.Data.RawSE3))) //SE3
 
-	binary.Write(file, binary.LittleEndian, f.Audio.Header.CurrentFrameSpeed)
-	binary.Write(file, binary.LittleEndian, f.Audio.Header.RecordingBGMFrameSpeed)
+	binary.Write(file, binary.LittleEndian, byte(8)-f.Audio.Header.CurrentFrameSpeed)
+	binary.Write(file, binary.LittleEndian, byte(8)-f.Audio.Header.RecordingBGMFrameSpeed)
 	binary.Write(file, binary.LittleEndian, make([]byte, 14))
 
 	binary.Write(file, binary.LittleEndian, f.Audio.Data.RawBGM)
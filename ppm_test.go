@@ -0,0 +1,215 @@
+package ppmlib
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testFrames(count int) []*Frame {
+	frames := make([]*Frame, count)
+	for i := range frames {
+		frame := NewFrame()
+		frame.Layer1.PenColor = PenColorInverted
+		frame.Layer2.PenColor = PenColorRed
+		frame.FirstByteHeader = 0x80 | byte(frame.Layer1.PenColor)<<1 | byte(frame.Layer2.PenColor)<<3
+		for x := 0; x < 16; x++ {
+			frame.Layer1.Set(x+i, 10, true)
+			frame.Layer2.Set(x, 20+i, true)
+		}
+
+		frames[i] = frame
+	}
+
+	return frames
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		author string
+		want   string
+		speed  byte
+		frames int
+		audio  []byte
+	}{
+		{"short author", "Tester", "Tester", 6, 3, nil},
+		{"eleven character author", "ElevenChars", "ElevenChars", 3, 1, nil},
+		{"author is truncated", "TwelveLetter", "TwelveLette", 8, 2, nil},
+		{"with sound", "Tester", "Tester", 5, 4, bytes.Repeat([]byte{0x17, 0x71}, 32)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			author, err := NewAuthor(test.author, 0x1234567890)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := CreateFile(author, testFrames(test.frames), test.audio)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Audio.Header.CurrentFrameSpeed = test.speed
+			file.Audio.Header.RecordingBGMFrameSpeed = test.speed
+
+			path := filepath.Join(t.TempDir(), "test.ppm")
+			if err := file.Save(path); err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if name := strings.TrimRight(read.CurrentAuthor.Name, "\x00"); name != test.want {
+				t.Errorf("author = %q, want %q", name, test.want)
+			}
+			if len(read.Frames) != test.frames {
+				t.Errorf("%d frames, want %d", len(read.Frames), test.frames)
+			}
+			if read.Framerate != ppmFramerates[test.speed] {
+				t.Errorf("framerate = %v, want %v", read.Framerate, ppmFramerates[test.speed])
+			}
+			if !bytes.Equal(read.RootFileFragment, file.RootFileFragment) || len(read.RootFileFragment) != 8 {
+				t.Errorf("root file fragment = %x, want %x", read.RootFileFragment, file.RootFileFragment)
+			}
+			if !bytes.Equal(read.Audio.Data.RawBGM, test.audio) {
+				t.Errorf("bgm = %x, want %x", read.Audio.Data.RawBGM, test.audio)
+			}
+		})
+	}
+}
+
+// testTone encodes n samples of a sine wave at freq Hz, played at 8192 Hz.
+// Each nibble is chosen against the decoder's own state, so the tone
+// decodes closely to the sine.
+func testTone(freq float64, n int) []byte {
+	state := &adpcmState{}
+	out := make([]byte, 0, n/2)
+
+	for i := 0; i+1 < n; i += 2 {
+		low := testNibble(state, int(6000*math.Sin(2*math.Pi*freq*float64(i)/8192)))
+		high := testNibble(state, int(6000*math.Sin(2*math.Pi*freq*float64(i+1)/8192)))
+		out = append(out, byte(low|high<<4))
+	}
+
+	return out
+}
+
+func testNibble(state *adpcmState, sample int) int {
+	step := stepTable[state.stepIndex]
+	delta := sample - state.predictor
+
+	nibble := 0
+	if delta < 0 {
+		nibble = 8
+		delta = -delta
+	}
+	if delta >= step {
+		nibble |= 4
+		delta -= step
+	}
+	if delta >= step>>1 {
+		nibble |= 2
+		delta -= step >> 1
+	}
+	if delta >= step>>2 {
+		nibble |= 1
+	}
+
+	state.next(nibble)
+
+	return nibble
+}
+
+// testFlipnote returns a 12 fps flipnote with a BGM track and two sound
+// effects, SE1 played every sixth frame and SE2 on frames 3, 13 and so on.
+func testFlipnote(frames int) *PPMFile {
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		panic(err)
+	}
+
+	file, err := CreateFile(author, testFrames(frames), testTone(440, 8192*2))
+	if err != nil {
+		panic(err)
+	}
+
+	file.FrameCount = uint16(frames)
+	file.Framerate = 12
+	file.BGMRate = 12
+	file.Audio.Header.CurrentFrameSpeed = 6
+	file.Audio.Header.RecordingBGMFrameSpeed = 6
+
+	file.Audio.Data.RawSE1 = testTone(880, 2048)
+	file.Audio.Header.SE1TrackSize = uint32(len(file.Audio.Data.RawSE1))
+	file.Audio.Data.RawSE2 = testTone(220, 4096)
+	file.Audio.Header.SE2TrackSize = uint32(len(file.Audio.Data.RawSE2))
+
+	file.SoundEffectFlags = make([]byte, frames)
+	for i := range file.SoundEffectFlags {
+		if i%6 == 0 {
+			file.SoundEffectFlags[i] |= 1
+		}
+		if i%10 == 3 {
+			file.SoundEffectFlags[i] |= 2
+		}
+	}
+
+	return file
+}
+
+func TestParseAppliesDiffFrames(t *testing.T) {
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every frame after the first is stored as the difference to the one
+	// before it, so reading them back depends on the previous frame being
+	// complete
+	full := testFrames(40)
+	frames := []*Frame{full[0]}
+	for i := 1; i < len(full); i++ {
+		diff := NewFrame()
+		diff.FirstByteHeader = full[i].FirstByteHeader &^ 0x80
+
+		for _, layers := range [][3]*Layer{{diff.Layer1, full[i].Layer1, full[i-1].Layer1}, {diff.Layer2, full[i].Layer2, full[i-1].Layer2}} {
+			for j := range layers[0].layerData {
+				layers[0].layerData[j] = layers[1].layerData[j] ^ layers[2].layerData[j]
+			}
+			for y := 0; y < 192; y++ {
+				layers[0].SetLineEncoding(y, layers[0].ChooseLineEncoding(y))
+			}
+		}
+
+		frames = append(frames, diff)
+	}
+
+	file, err := CreateFile(author, frames, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Audio.Header.CurrentFrameSpeed = 6
+	file.Audio.Header.RecordingBGMFrameSpeed = 6
+
+	path := filepath.Join(t.TempDir(), "test.ppm")
+	if err := file.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, frame := range read.Frames {
+		if !bytes.Equal(frame.Layer1.layerData, full[i].Layer1.layerData) || !bytes.Equal(frame.Layer2.layerData, full[i].Layer2.layerData) {
+			t.Fatalf("frame %d differs from the frame that was saved", i)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package ppmlib
 
 import (
+	"errors"
 	"image"
 	"image/color"
 )
@@ -12,59 +13,318 @@ var (
 	blue  = color.RGBA{0, 0, 255, 255}
 )
 
+// Palette holds the colors a frame is drawn with. Paletted images use it in
+// this order, so index 0 is white, 1 black, 2 red and 3 blue.
+type Palette struct {
+	White color.RGBA
+	Black color.RGBA
+	Red   color.RGBA
+	Blue  color.RGBA
+}
+
+var (
+	// PaletteDefault uses pure white, black, red and blue.
+	PaletteDefault = Palette{White: white, Black: black, Red: red, Blue: blue}
+	// PaletteDSi takes its red and blue from the fixed palette Flipnote
+	// Studio draws thumbnails with, the only pen colors the DSi stores
+	// itself. It is an approximation of the DSi screen, not a measurement.
+	PaletteDSi = Palette{
+		White: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		Black: color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		Red:   color.RGBA{0xFF, 0x48, 0x44, 0xFF},
+		Blue:  color.RGBA{0x48, 0x40, 0xFF, 0xFF},
+	}
+	// PaletteHatena matches the Flipnote Hatena web player.
+	PaletteHatena = Palette{
+		White: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		Black: color.RGBA{0x0E, 0x0E, 0x0E, 0xFF},
+		Red:   color.RGBA{0xFF, 0x2A, 0x2A, 0xFF},
+		Blue:  color.RGBA{0x0A, 0x39, 0xFF, 0xFF},
+	}
+)
+
+const (
+	paletteIndexWhite = iota
+	paletteIndexBlack
+	paletteIndexRed
+	paletteIndexBlue
+	// paletteIndexTransparent is only present with TransparentPaper.
+	paletteIndexTransparent
+)
+
+func (p *Palette) colors() []color.RGBA {
+	return []color.RGBA{p.White, p.Black, p.Red, p.Blue, {}}
+}
+
+// ColorPalette returns the palette as used by paletted images.
+func (p *Palette) ColorPalette() color.Palette {
+	return color.Palette{p.White, p.Black, p.Red, p.Blue}
+}
+
+// Renderer draws frames into images. The zero value renders at 256x192 with
+// PaletteDefault.
+type Renderer struct {
+	// Scale is an integer upscale factor, 1 when zero.
+	Scale int
+	// Palette is PaletteDefault when nil.
+	Palette *Palette
+	// RGBA makes Render return an *image.RGBA instead of an *image.Paletted.
+	RGBA bool
+	// TransparentPaper leaves the paper fully transparent. Paletted images
+	// get a fifth, transparent palette entry for it.
+	TransparentPaper bool
+	// HideLayer1 and HideLayer2 leave a layer out. PPMFile.NewRenderer sets
+	// them from the flipnote's AnimationFlags.
+	HideLayer1 bool
+	HideLayer2 bool
+}
+
+// frameInks holds the palette indices a frame is drawn with and which of its
+// layers are drawn.
+type frameInks struct {
+	paper  uint8
+	layer1 uint8
+	layer2 uint8
+
+	hideLayer1 bool
+	hideLayer2 bool
+}
+
+// GetImage renders the frame at 256x192, leaving out the layers its
+// flipnote's AnimationFlags hide.
 func (f *Frame) GetImage() *image.Paletted {
-	img := image.NewPaletted(image.Rect(0, 0, 256, 192), []color.Color{white, black, red, blue})
+	img, _ := f.file.NewRenderer().RenderPaletted(f, nil)
+	return img
+}
 
-	background := white
-	if f.PaperColor == PaperColorBlack {
-		background = black
+// Bounds returns the size of the images the renderer produces.
+func (r *Renderer) Bounds() image.Rectangle {
+	scale := r.scale()
+	return image.Rect(0, 0, 256*scale, 192*scale)
+}
+
+// Render draws a frame into a new image, paletted or RGBA depending on the
+// renderer's RGBA option.
+func (r *Renderer) Render(f *Frame) (image.Image, error) {
+	if r.RGBA {
+		return r.RenderRGBA(f, nil)
 	}
 
-	layer1Stroke := black
-	if f.Layer1.PenColor == PenColorRed {
-		layer1Stroke = red
+	return r.RenderPaletted(f, nil)
+}
+
+// RenderPaletted draws a frame into dst, or a new image when dst is nil.
+// dst must have the renderer's bounds.
+func (r *Renderer) RenderPaletted(f *Frame, dst *image.Paletted) (*image.Paletted, error) {
+	if r.scale() < 1 {
+		return nil, errors.New("invalid scale")
 	}
-	if f.Layer1.PenColor == PenColorBlue {
-		layer1Stroke = blue
+
+	if dst == nil {
+		palette := r.palette().ColorPalette()
+		if r.TransparentPaper {
+			palette = append(palette, color.RGBA{})
+		}
+
+		dst = image.NewPaletted(r.Bounds(), palette)
+	} else if dst.Rect != r.Bounds() {
+		return nil, errors.New("destination image has the wrong size")
+	} else if r.TransparentPaper && len(dst.Palette) <= paletteIndexTransparent {
+		return nil, errors.New("destination palette has no transparent entry")
 	}
-	if f.Layer1.PenColor == PenColorInverted {
-		if background == black {
-			layer1Stroke = white
-		} else {
-			layer1Stroke = black
+
+	inks := r.inks(f)
+	scale := r.scale()
+
+	for y := 0; y < 192; y++ {
+		row := dst.Pix[y*scale*dst.Stride:]
+		o := 0
+
+		for x := 0; x < 256; x++ {
+			index := inks.index(f, 256*y+x)
+			for i := 0; i < scale; i++ {
+				row[o] = index
+				o++
+			}
 		}
+
+		repeatRow(dst.Pix, dst.Stride, y, scale, o)
 	}
 
-	layer2Stroke := black
-	if f.Layer2.PenColor == PenColorRed {
-		layer2Stroke = red
+	return dst, nil
+}
+
+// RenderRGBA draws a frame into dst, or a new image when dst is nil.
+// dst must have the renderer's bounds.
+func (r *Renderer) RenderRGBA(f *Frame, dst *image.RGBA) (*image.RGBA, error) {
+	return r.renderRGBA(r.inks(f), f, dst)
+}
+
+// RenderLayer draws a single layer of a frame, 1 or 2, into dst, or a new
+// image when dst is nil. The layer's lines are drawn in its pen color and
+// everything else is left transparent. The layer is drawn even if the
+// renderer hides it.
+func (r *Renderer) RenderLayer(f *Frame, layer int, dst *image.RGBA) (*image.RGBA, error) {
+	inks := r.inks(f)
+	inks.paper = paletteIndexTransparent
+
+	switch layer {
+	case 1:
+		inks.hideLayer1, inks.hideLayer2 = false, true
+	case 2:
+		inks.hideLayer1, inks.hideLayer2 = true, false
+	default:
+		return nil, errors.New("invalid layer")
 	}
 
-	if f.Layer2.PenColor == PenColorBlue {
-		layer2Stroke = blue
+	return r.renderRGBA(inks, f, dst)
+}
+
+func (r *Renderer) renderRGBA(inks frameInks, f *Frame, dst *image.RGBA) (*image.RGBA, error) {
+	if r.scale() < 1 {
+		return nil, errors.New("invalid scale")
 	}
 
-	if f.Layer2.PenColor == PenColorInverted {
-		if background == black {
-			layer2Stroke = white
-		} else {
-			layer2Stroke = black
-		}
+	if dst == nil {
+		dst = image.NewRGBA(r.Bounds())
+	} else if dst.Rect != r.Bounds() {
+		return nil, errors.New("destination image has the wrong size")
 	}
 
-	for y := 0; y <= 191; y++ {
-		for x := 0; x <= 255; x++ {
-			img.Set(x, y, background)
+	colors := r.palette().colors()
+	scale := r.scale()
+
+	for y := 0; y < 192; y++ {
+		row := dst.Pix[y*scale*dst.Stride:]
+		o := 0
 
-			if f.Layer2.Get(x, y) {
-				img.Set(x, y, layer2Stroke)
+		for x := 0; x < 256; x++ {
+			c := colors[inks.index(f, 256*y+x)]
+			for i := 0; i < scale; i++ {
+				row[o], row[o+1], row[o+2], row[o+3] = c.R, c.G, c.B, c.A
+				o += 4
 			}
+		}
+
+		repeatRow(dst.Pix, dst.Stride, y, scale, o)
+	}
 
-			if f.Layer1.Get(x, y) {
-				img.Set(x, y, layer1Stroke)
+	return dst, nil
+}
+
+// RenderThumbnail draws the flipnote's 64x48 thumbnail, scaled like frames
+// are. The thumbnail keeps its own fixed colors.
+func (r *Renderer) RenderThumbnail(f *PPMFile) (*image.Paletted, error) {
+	scale := r.scale()
+	if scale < 1 {
+		return nil, errors.New("invalid scale")
+	}
+
+	img, err := f.ThumbnailImage()
+	if err != nil {
+		return nil, err
+	}
+
+	if scale == 1 {
+		return img, nil
+	}
+
+	dst := image.NewPaletted(image.Rect(0, 0, 64*scale, 48*scale), img.Palette)
+	for y := 0; y < 48; y++ {
+		src := img.Pix[y*img.Stride:]
+		row := dst.Pix[y*scale*dst.Stride:]
+		o := 0
+
+		for x := 0; x < 64; x++ {
+			for i := 0; i < scale; i++ {
+				row[o] = src[x]
+				o++
 			}
 		}
+
+		repeatRow(dst.Pix, dst.Stride, y, scale, o)
 	}
 
-	return img
+	return dst, nil
+}
+
+func (r *Renderer) inks(f *Frame) frameInks {
+	paper, layer1, layer2 := frameColorIndices(f)
+	if r.TransparentPaper {
+		paper = paletteIndexTransparent
+	}
+
+	return frameInks{
+		paper:      paper,
+		layer1:     layer1,
+		layer2:     layer2,
+		hideLayer1: r.HideLayer1,
+		hideLayer2: r.HideLayer2,
+	}
+}
+
+// index returns the palette index of pixel p, counted from the top left.
+func (inks *frameInks) index(f *Frame, p int) uint8 {
+	bit := byte(1) << (p & 7)
+
+	if !inks.hideLayer1 && f.Layer1.layerData[p>>3]&bit != 0 {
+		return inks.layer1
+	}
+	if !inks.hideLayer2 && f.Layer2.layerData[p>>3]&bit != 0 {
+		return inks.layer2
+	}
+
+	return inks.paper
+}
+
+// repeatRow copies the first size bytes of scaled row y over the remaining
+// rows of the scale.
+func repeatRow(pix []byte, stride int, y int, scale int, size int) {
+	row := pix[y*scale*stride:]
+
+	for i := 1; i < scale; i++ {
+		copy(pix[(y*scale+i)*stride:(y*scale+i)*stride+size], row[:size])
+	}
+}
+
+func (r *Renderer) scale() int {
+	if r.Scale == 0 {
+		return 1
+	}
+
+	return r.Scale
+}
+
+func (r *Renderer) palette() *Palette {
+	if r.Palette == nil {
+		return &PaletteDefault
+	}
+
+	return r.Palette
+}
+
+// frameColorIndices returns the palette indices of the paper and of the
+// pens of both layers.
+func frameColorIndices(f *Frame) (uint8, uint8, uint8) {
+	paper := uint8(paletteIndexWhite)
+	if f.PaperColor == PaperColorBlack {
+		paper = paletteIndexBlack
+	}
+
+	return paper, penColorIndex(f.Layer1.PenColor, paper), penColorIndex(f.Layer2.PenColor, paper)
+}
+
+func penColorIndex(pen PenColor, paper uint8) uint8 {
+	switch pen {
+	case PenColorRed:
+		return paletteIndexRed
+	case PenColorBlue:
+		return paletteIndexBlue
+	case PenColorInverted:
+		if paper == paletteIndexBlack {
+			return paletteIndexWhite
+		}
+	}
+
+	return paletteIndexBlack
 }
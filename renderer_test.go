@@ -0,0 +1,90 @@
+package ppmlib
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRendererMatchesLayers(t *testing.T) {
+	frame := testFrames(3)[2]
+	frame.PaperColor = PaperColorBlack
+	frame.Layer1.Set(5, 5, true)
+	frame.Layer2.Set(5, 5, true)
+
+	for _, renderer := range []*Renderer{
+		{},
+		{Scale: 3, Palette: &PaletteHatena},
+		{Scale: 2, TransparentPaper: true},
+		{HideLayer1: true},
+		{Scale: 2, HideLayer2: true},
+	} {
+		paletted, err := renderer.RenderPaletted(frame, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rgba, err := renderer.RenderRGBA(frame, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		colors := renderer.palette().colors()
+		scale := renderer.scale()
+
+		for y := 0; y < 192*scale; y++ {
+			for x := 0; x < 256*scale; x++ {
+				var want int
+				switch {
+				case frame.Layer1.Get(x/scale, y/scale) && !renderer.HideLayer1:
+					want = paletteIndexWhite
+				case frame.Layer2.Get(x/scale, y/scale) && !renderer.HideLayer2:
+					want = paletteIndexRed
+				case renderer.TransparentPaper:
+					want = paletteIndexTransparent
+				default:
+					want = paletteIndexBlack
+				}
+
+				if got := paletted.ColorIndexAt(x, y); int(got) != want {
+					t.Fatalf("%+v: paletted pixel %d,%d is %d, want %d", renderer, x, y, got, want)
+				}
+
+				if got := rgba.RGBAAt(x, y); got != colors[want] {
+					t.Fatalf("%+v: rgba pixel %d,%d is %v, want %v", renderer, x, y, got, colors[want])
+				}
+			}
+		}
+	}
+}
+
+func TestRendererRejectsWrongDestination(t *testing.T) {
+	renderer := &Renderer{Scale: 2}
+	frame := NewFrame()
+
+	small, err := (&Renderer{}).RenderRGBA(frame, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := renderer.RenderRGBA(frame, small); err == nil {
+		t.Error("a destination of the wrong size was accepted")
+	}
+
+	if _, err := (&Renderer{Scale: -1}).RenderRGBA(frame, nil); err == nil {
+		t.Error("a negative scale was accepted")
+	}
+
+	if (color.RGBA{}) != PaletteDefault.colors()[paletteIndexTransparent] {
+		t.Error("the transparent palette entry is not transparent")
+	}
+}
+
+func BenchmarkRenderRGBA(b *testing.B) {
+	renderer := &Renderer{Scale: 2}
+	frame := testFrames(1)[0]
+	dst, _ := renderer.RenderRGBA(frame, nil)
+
+	for i := 0; i < b.N; i++ {
+		renderer.RenderRGBA(frame, dst)
+	}
+}
@@ -0,0 +1,167 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"math"
+)
+
+type SpriteSheetOptions struct {
+	RenderOptions
+	FrameRange
+	// Columns of the grid, enough for a roughly square sheet when zero.
+	Columns int
+	// Dedupe stores identical frames only once, their atlas entries share
+	// a rectangle.
+	Dedupe bool
+	// TransparentPaper leaves the paper fully transparent.
+	TransparentPaper bool
+	// ImageName is recorded in the atlas as the sheet's file name.
+	ImageName string
+}
+
+type SpriteSheetAtlas struct {
+	Frames []SpriteSheetFrame `json:"frames"`
+	Meta   SpriteSheetMeta    `json:"meta"`
+}
+
+type SpriteSheetFrame struct {
+	// Index is the frame's index in the flipnote.
+	Index int             `json:"index"`
+	Frame SpriteSheetRect `json:"frame"`
+	// Duration is how long the frame is shown, in milliseconds.
+	Duration int `json:"duration"`
+}
+
+type SpriteSheetRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type SpriteSheetMeta struct {
+	Image     string          `json:"image,omitempty"`
+	Size      SpriteSheetSize `json:"size"`
+	Scale     int             `json:"scale"`
+	Framerate float32         `json:"framerate"`
+	Loop      bool            `json:"loop"`
+}
+
+type SpriteSheetSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// ExportSpriteSheet packs the frames into a grid, written to w as a PNG, and
+// writes a JSON atlas with each frame's rectangle and duration to atlasW.
+// Durations are whole milliseconds that add up to the exact total length.
+func (f *PPMFile) ExportSpriteSheet(w io.Writer, atlasW io.Writer, opts *SpriteSheetOptions) error {
+	if opts == nil {
+		opts = &SpriteSheetOptions{}
+	}
+
+	start, end, err := f.frameRange(opts.Start, opts.End)
+	if err != nil {
+		return err
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	if opts.Columns < 0 {
+		return errors.New("invalid column count")
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	renderer.TransparentPaper = opts.TransparentPaper
+
+	// cells holds the images that get a place on the sheet, cellIndex maps
+	// every exported frame to one of them
+	cells := make([]*image.Paletted, 0, end-start)
+	cellIndex := make([]int, 0, end-start)
+	seen := make(map[string]int)
+
+	for i := start; i < end; i++ {
+		img, err := renderer.RenderPaletted(f.Frames[i], nil)
+		if err != nil {
+			return err
+		}
+
+		if opts.Dedupe {
+			if cell, ok := seen[string(img.Pix)]; ok {
+				cellIndex = append(cellIndex, cell)
+				continue
+			}
+			seen[string(img.Pix)] = len(cells)
+		}
+
+		cellIndex = append(cellIndex, len(cells))
+		cells = append(cells, img)
+	}
+
+	columns := opts.Columns
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(cells)))))
+	}
+	if columns > len(cells) {
+		columns = len(cells)
+	}
+	rows := (len(cells) + columns - 1) / columns
+
+	cellSize := renderer.Bounds().Size()
+	sheet := image.NewPaletted(image.Rect(0, 0, columns*cellSize.X, rows*cellSize.Y), cells[0].Palette)
+	rects := make([]SpriteSheetRect, len(cells))
+
+	for i, cell := range cells {
+		x, y := (i%columns)*cellSize.X, (i/columns)*cellSize.Y
+		rects[i] = SpriteSheetRect{X: x, Y: y, W: cellSize.X, H: cellSize.Y}
+
+		for row := 0; row < cellSize.Y; row++ {
+			copy(sheet.Pix[(y+row)*sheet.Stride+x:], cell.Pix[row*cell.Stride:row*cell.Stride+cellSize.X])
+		}
+	}
+
+	atlas := SpriteSheetAtlas{
+		Frames: make([]SpriteSheetFrame, 0, end-start),
+		Meta: SpriteSheetMeta{
+			Image:     opts.ImageName,
+			Size:      SpriteSheetSize{W: sheet.Rect.Dx(), H: sheet.Rect.Dy()},
+			Scale:     scale,
+			Framerate: f.Framerate,
+			Loop:      f.animationFlags(opts.Flags).Loop,
+		},
+	}
+
+	delays := frameDelays(f.Framerate, end-start, 1000)
+	for i, cell := range cellIndex {
+		atlas.Frames = append(atlas.Frames, SpriteSheetFrame{
+			Index:    start + i,
+			Frame:    rects[cell],
+			Duration: delays[i],
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(buf, sheet); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(atlasW)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(atlas)
+}
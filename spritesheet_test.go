@@ -0,0 +1,125 @@
+package ppmlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func exportSpriteSheet(t *testing.T, file *PPMFile, opts *SpriteSheetOptions) (image.Image, SpriteSheetAtlas) {
+	t.Helper()
+
+	sheet, atlasJSON := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := file.ExportSpriteSheet(sheet, atlasJSON, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var atlas SpriteSheetAtlas
+	if err := json.Unmarshal(atlasJSON.Bytes(), &atlas); err != nil {
+		t.Fatal(err)
+	}
+
+	return img, atlas
+}
+
+func TestExportSpriteSheet(t *testing.T) {
+	file := testFlipnote(9)
+	opts := &SpriteSheetOptions{
+		RenderOptions: RenderOptions{Scale: 2},
+		FrameRange:    FrameRange{Start: 1, End: 8},
+		ImageName:     "sheet.png",
+	}
+
+	img, atlas := exportSpriteSheet(t, file, opts)
+
+	// 7 frames fit a 3x3 grid
+	if img.Bounds() != image.Rect(0, 0, 3*512, 3*384) {
+		t.Fatalf("sheet is %v", img.Bounds())
+	}
+	if atlas.Meta.Size != (SpriteSheetSize{W: 3 * 512, H: 3 * 384}) || atlas.Meta.Scale != 2 || atlas.Meta.Image != "sheet.png" {
+		t.Errorf("unexpected meta %+v", atlas.Meta)
+	}
+	if len(atlas.Frames) != 7 {
+		t.Fatalf("atlas has %d frames, want 7", len(atlas.Frames))
+	}
+
+	renderer := file.renderer(2, nil, nil)
+	delays := frameDelays(file.Framerate, 7, 1000)
+	total := 0
+
+	for i, frame := range atlas.Frames {
+		want := SpriteSheetRect{X: i % 3 * 512, Y: i / 3 * 384, W: 512, H: 384}
+		if frame.Index != i+1 || frame.Frame != want {
+			t.Errorf("entry %d is frame %d at %+v, want frame %d at %+v", i, frame.Index, frame.Frame, i+1, want)
+		}
+
+		if frame.Duration != delays[i] {
+			t.Errorf("entry %d lasts %d ms, want %d", i, frame.Duration, delays[i])
+		}
+		total += frame.Duration
+
+		rendered, err := renderer.RenderPaletted(file.Frames[frame.Index], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := frame.Frame
+		for y := 0; y < r.H; y++ {
+			for x := 0; x < r.W; x++ {
+				if !equalColor(img.At(r.X+x, r.Y+y), rendered.At(x, y)) {
+					t.Fatalf("entry %d differs from frame %d at %d,%d", i, frame.Index, x, y)
+				}
+			}
+		}
+	}
+
+	// 7 frames at 12 fps last 583.3 ms
+	if total != 583 {
+		t.Errorf("the durations add up to %d ms, want 583", total)
+	}
+}
+
+func TestExportSpriteSheetDedupe(t *testing.T) {
+	frames := testFrames(3)
+	file := testFlipnote(4)
+	file.Frames = []*Frame{frames[0], frames[1], frames[1].clone(), frames[2]}
+
+	img, atlas := exportSpriteSheet(t, file, &SpriteSheetOptions{Columns: 2, Dedupe: true})
+
+	if img.Bounds() != image.Rect(0, 0, 2*256, 2*192) {
+		t.Fatalf("sheet is %v", img.Bounds())
+	}
+
+	rects := []SpriteSheetRect{
+		{X: 0, Y: 0, W: 256, H: 192},
+		{X: 256, Y: 0, W: 256, H: 192},
+		{X: 256, Y: 0, W: 256, H: 192},
+		{X: 0, Y: 192, W: 256, H: 192},
+	}
+	for i, frame := range atlas.Frames {
+		if frame.Frame != rects[i] {
+			t.Errorf("entry %d is at %+v, want %+v", i, frame.Frame, rects[i])
+		}
+	}
+}
+
+func TestExportSpriteSheetErrors(t *testing.T) {
+	file := testFlipnote(3)
+
+	for name, opts := range map[string]*SpriteSheetOptions{
+		"negative columns": {Columns: -1},
+		"negative scale":   {RenderOptions: RenderOptions{Scale: -1}},
+		"invalid range":    {FrameRange: FrameRange{End: 4}},
+	} {
+		if err := file.ExportSpriteSheet(&bytes.Buffer{}, &bytes.Buffer{}, opts); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
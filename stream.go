@@ -0,0 +1,256 @@
+package ppmlib
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/RinLovesYou/ppmlib-go/utils"
+)
+
+type AudioStreamOptions struct {
+	// Stereo duplicates every sample into a left and right channel.
+	Stereo bool
+}
+
+// AudioStream produces the same mix as GetAudioMasterPcm as 16-bit little
+// endian PCM, decoding and mixing one frame at a time.
+type AudioStream struct {
+	decoder *AdpcmDecoder
+	rate    int
+	stereo  bool
+
+	length    int
+	pos       int
+	nextFrame int
+
+	bgm    *trackCursor
+	voices []*trackCursor
+
+	buf []byte
+}
+
+// trackCursor decodes a track on demand, starting at the output sample start.
+type trackCursor struct {
+	track  PPMAudioTrack
+	src    []byte
+	start  int
+	length int
+	ratio  float32
+
+	state   adpcmState
+	decoded int
+	sample  int16
+}
+
+func (f *PPMFile) AudioStream(rate int, opts *AudioStreamOptions) *AudioStream {
+	if opts == nil {
+		opts = &AudioStreamOptions{}
+	}
+
+	if rate == 0 {
+		rate = 32768
+	}
+
+	stream := &AudioStream{
+		decoder: NewAudioDecoder(f),
+		rate:    rate,
+		stereo:  opts.Stereo,
+	}
+	stream.length = stream.decoder.timelineSize(rate)
+	stream.seekSample(0)
+
+	return stream
+}
+
+func (s *AudioStream) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		if len(s.buf) == 0 {
+			if s.pos >= s.length {
+				break
+			}
+
+			s.render()
+		}
+
+		copied := copy(p[n:], s.buf)
+		s.buf = s.buf[copied:]
+		n += copied
+	}
+
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// Seek moves to a byte offset in the PCM output. Offsets are rounded down
+// to a whole sample.
+func (s *AudioStream) Seek(offset int64, whence int) (int64, error) {
+	size := int64(s.sampleSize())
+	current := int64(s.pos)*size - int64(len(s.buf))
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += current
+	case io.SeekEnd:
+		offset += int64(s.length) * size
+	default:
+		return current, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return current, errors.New("negative position")
+	}
+
+	sample := int(offset / size)
+	if sample > s.length {
+		sample = s.length
+	}
+
+	s.seekSample(sample)
+
+	return int64(sample) * size, nil
+}
+
+// SeekFrame moves to the first sample of the frame at index.
+func (s *AudioStream) SeekFrame(index int) error {
+	if index < 0 || index >= int(s.decoder.flipnote.FrameCount) {
+		return errors.New("frame index out of range")
+	}
+
+	s.seekSample(s.decoder.frameOffset(index, s.rate))
+
+	return nil
+}
+
+// Len returns the total size of the PCM output in bytes.
+func (s *AudioStream) Len() int64 {
+	return int64(s.length) * int64(s.sampleSize())
+}
+
+func (s *AudioStream) sampleSize() int {
+	if s.stereo {
+		return 4
+	}
+
+	return 2
+}
+
+func (s *AudioStream) seekSample(sample int) {
+	d := s.decoder
+	frameCount := int(d.flipnote.FrameCount)
+
+	s.buf = nil
+	s.pos = sample
+	s.voices = nil
+	s.bgm = nil
+
+	if d.hasTrack(BGM) {
+		s.bgm = s.newCursor(BGM, 0)
+	}
+
+	s.nextFrame = 0
+	for s.nextFrame < frameCount && d.frameOffset(s.nextFrame, s.rate) <= sample {
+		s.startVoices(s.nextFrame)
+		s.nextFrame++
+	}
+}
+
+func (s *AudioStream) newCursor(track PPMAudioTrack, start int) *trackCursor {
+	src, _ := s.decoder.flipnote.Audio.Data.trackData(track)
+	srcFreq := s.decoder.trackFrequency(track)
+
+	return &trackCursor{
+		track:  track,
+		src:    src,
+		start:  start,
+		length: int(int64(len(src)*2) * int64(s.rate) / int64(srcFreq)),
+		ratio:  float32(srcFreq) / float32(s.rate),
+	}
+}
+
+func (s *AudioStream) startVoices(frame int) {
+	for _, track := range []PPMAudioTrack{SE1, SE2, SE3} {
+		if s.decoder.hasTrack(track) && s.decoder.triggers(frame, track) {
+			s.voices = append(s.voices, s.newCursor(track, s.decoder.frameOffset(frame, s.rate)))
+		}
+	}
+
+	// mix in the same order as GetAudioMasterPcm so clipping matches
+	sort.SliceStable(s.voices, func(i, j int) bool {
+		return s.voices[i].track < s.voices[j].track
+	})
+}
+
+// render decodes the samples up to the start of the next frame.
+func (s *AudioStream) render() {
+	frameCount := int(s.decoder.flipnote.FrameCount)
+
+	end := s.length
+	if s.nextFrame < frameCount {
+		end = s.decoder.frameOffset(s.nextFrame, s.rate)
+		if end > s.length {
+			end = s.length
+		}
+	}
+
+	buf := make([]byte, 0, (end-s.pos)*s.sampleSize())
+	for ; s.pos < end; s.pos++ {
+		mixed := 0
+
+		if s.bgm != nil {
+			if sample, ok := s.bgm.sampleAt(s.pos); ok {
+				mixed = utils.Clamp(mixed+int(sample/2), -32768, 32767)
+			} else {
+				s.bgm = nil
+			}
+		}
+
+		active := s.voices[:0]
+		for _, voice := range s.voices {
+			if sample, ok := voice.sampleAt(s.pos); ok {
+				mixed = utils.Clamp(mixed+int(sample/2), -32768, 32767)
+				active = append(active, voice)
+			}
+		}
+		s.voices = active
+
+		buf = append(buf, byte(mixed), byte(mixed>>8))
+		if s.stereo {
+			buf = append(buf, byte(mixed), byte(mixed>>8))
+		}
+	}
+	s.buf = buf
+
+	if s.pos == end && s.nextFrame < frameCount {
+		s.startVoices(s.nextFrame)
+		s.nextFrame++
+	}
+}
+
+// sampleAt returns the nearest neighbour sample for the output sample pos,
+// or false once the track has ended.
+func (c *trackCursor) sampleAt(pos int) (int16, bool) {
+	index := int(float32(pos-c.start) * c.ratio)
+
+	if pos-c.start >= c.length || index >= len(c.src)*2 {
+		return 0, false
+	}
+
+	for c.decoded <= index {
+		b := c.src[c.decoded>>1]
+		if c.decoded&1 == 0 {
+			c.sample = c.state.next(int(b & 0xF))
+		} else {
+			c.sample = c.state.next(int(b >> 4))
+		}
+		c.decoded++
+	}
+
+	return c.sample, true
+}
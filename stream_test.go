@@ -0,0 +1,177 @@
+package ppmlib
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func readStream(t *testing.T, r io.Reader, samples int) []int16 {
+	t.Helper()
+
+	data := make([]byte, samples*2)
+	n, err := io.ReadFull(r, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+
+	pcm := make([]int16, n/2)
+	for i := range pcm {
+		pcm[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+
+	return pcm
+}
+
+func TestAudioStreamMatchesMaster(t *testing.T) {
+	file := testFlipnote(30)
+
+	for _, rate := range []int{8192, 32768, 44100} {
+		master, err := NewAudioDecoder(file).GetAudioMasterPcm(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream := file.AudioStream(rate, nil)
+		if stream.Len() != int64(len(master)*2) {
+			t.Errorf("%d: Len is %d, want %d", rate, stream.Len(), len(master)*2)
+		}
+
+		if got := readStream(t, stream, len(master)+1); !equalSamples(got, master) {
+			t.Errorf("%d: stream differs from the master mix", rate)
+		}
+
+		if n, err := stream.Read(make([]byte, 16)); n != 0 || err != io.EOF {
+			t.Errorf("%d: read %d bytes and %v at the end, want io.EOF", rate, n, err)
+		}
+	}
+}
+
+func TestAudioStreamStereo(t *testing.T) {
+	file := testFlipnote(12)
+
+	master, err := NewAudioDecoder(file).GetAudioMasterPcm(32768)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := file.AudioStream(32768, &AudioStreamOptions{Stereo: true})
+	if stream.Len() != int64(len(master)*4) {
+		t.Fatalf("Len is %d, want %d", stream.Len(), len(master)*4)
+	}
+
+	got := readStream(t, stream, len(master)*2)
+	if len(got) != len(master)*2 {
+		t.Fatalf("read %d samples, want %d", len(got), len(master)*2)
+	}
+
+	for i, sample := range master {
+		if got[i*2] != sample || got[i*2+1] != sample {
+			t.Fatalf("sample %d is %d/%d, want %d on both channels", i, got[i*2], got[i*2+1], sample)
+		}
+	}
+}
+
+func TestAudioStreamSeekFrame(t *testing.T) {
+	file := testFlipnote(30)
+	rate := 32768
+
+	decoder := NewAudioDecoder(file)
+	master, err := decoder.GetAudioMasterPcm(rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := file.AudioStream(rate, nil)
+
+	// seek backwards as well as forwards, the voices started before the
+	// frame have to be picked up again
+	for _, frame := range []int{17, 3, 0, 29, 6, 12} {
+		if err := stream.SeekFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		offset := decoder.frameOffset(frame, rate)
+		want := master[offset:]
+		if len(want) > 4000 {
+			want = want[:4000]
+		}
+
+		if got := readStream(t, stream, len(want)); !equalSamples(got, want) {
+			t.Errorf("frame %d: stream differs from the master mix at sample %d", frame, offset)
+		}
+	}
+
+	for _, frame := range []int{-1, 30} {
+		if err := stream.SeekFrame(frame); err == nil {
+			t.Errorf("frame %d: expected an error", frame)
+		}
+	}
+}
+
+func TestAudioStreamSeek(t *testing.T) {
+	file := testFlipnote(30)
+	rate := 8192
+
+	master, err := NewAudioDecoder(file).GetAudioMasterPcm(rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := file.AudioStream(rate, nil)
+
+	pos, err := stream.Seek(-200, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != stream.Len()-200 {
+		t.Errorf("SeekEnd moved to %d, want %d", pos, stream.Len()-200)
+	}
+	if got := readStream(t, stream, 101); !equalSamples(got, master[len(master)-100:]) {
+		t.Error("the last samples differ from the master mix")
+	}
+
+	// odd offsets are rounded down to a whole sample
+	pos, err = stream.Seek(1001, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 1000 {
+		t.Errorf("SeekStart moved to %d, want 1000", pos)
+	}
+
+	readStream(t, stream, 50)
+	pos, err = stream.Seek(100, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 1200 {
+		t.Errorf("SeekCurrent moved to %d, want 1200", pos)
+	}
+	if got := readStream(t, stream, 300); !equalSamples(got, master[600:900]) {
+		t.Error("samples after SeekCurrent differ from the master mix")
+	}
+
+	before, _ := stream.Seek(0, io.SeekCurrent)
+	if _, err := stream.Seek(-2, io.SeekStart); err == nil {
+		t.Error("a negative position was accepted")
+	}
+	if _, err := stream.Seek(-stream.Len()-2, io.SeekEnd); err == nil {
+		t.Error("a negative position from the end was accepted")
+	}
+	if after, _ := stream.Seek(0, io.SeekCurrent); after != before {
+		t.Errorf("a failed seek moved the stream from %d to %d", before, after)
+	}
+
+	// seeking past the end stops at the end
+	pos, err = stream.Seek(10, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != stream.Len() {
+		t.Errorf("seeking past the end moved to %d, want %d", pos, stream.Len())
+	}
+	if _, err := stream.Read(make([]byte, 2)); err != io.EOF {
+		t.Errorf("read %v at the end, want io.EOF", err)
+	}
+}
@@ -0,0 +1,303 @@
+package ppmlib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVGOptions configure the SVG exports. The drawing is resolution
+// independent, Scale only multiplies the width and height it is shown at.
+type SVGOptions struct {
+	RenderOptions
+	// Smooth rounds the corners of the traced outlines with quadratic
+	// curves. Straight runs stay straight.
+	Smooth bool
+	// TransparentPaper leaves out the paper rectangle.
+	TransparentPaper bool
+}
+
+// ExportSVG traces the frame's layers into filled outlines over a paper
+// rectangle and writes them as an SVG document.
+func (f *Frame) ExportSVG(w io.Writer, opts *SVGOptions) error {
+	if opts == nil {
+		opts = &SVGOptions{}
+	}
+
+	renderer := f.file.renderer(1, opts.Palette, opts.Flags)
+
+	bw := bufio.NewWriter(w)
+	if err := writeSVGHeader(bw, opts); err != nil {
+		return err
+	}
+
+	writeSVGFrame(bw, f, renderer, opts)
+	bw.WriteString("</svg>\n")
+
+	return bw.Flush()
+}
+
+// ExportAnimatedSVG writes every frame of the flipnote as a traced group of
+// an SVG document, shown one after another with SMIL animations. It loops
+// if the flipnote does, and stops on the last frame otherwise.
+func (f *PPMFile) ExportAnimatedSVG(w io.Writer, opts *SVGOptions) error {
+	if opts == nil {
+		opts = &SVGOptions{}
+	}
+
+	if len(f.Frames) == 0 {
+		return errors.New("flipnote has no frames")
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	renderer := f.renderer(1, opts.Palette, opts.Flags)
+	loop := f.animationFlags(opts.Flags).Loop
+
+	delays := frameDelays(f.Framerate, len(f.Frames), 1000)
+	total := 0
+	for _, delay := range delays {
+		total += delay
+	}
+
+	repeat := `repeatCount="indefinite"`
+	if !loop {
+		repeat = `repeatCount="1" fill="freeze"`
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeSVGHeader(bw, opts); err != nil {
+		return err
+	}
+
+	elapsed := 0
+	for i, frame := range f.Frames {
+		start, end := float64(elapsed)/float64(total), float64(elapsed+delays[i])/float64(total)
+		elapsed += delays[i]
+
+		values, keyTimes := "none;inline;none", fmt.Sprintf("0;%s;%s", svgNumber(start), svgNumber(end))
+		if i == len(f.Frames)-1 {
+			values, keyTimes = "none;inline", fmt.Sprintf("0;%s", svgNumber(start))
+		}
+
+		fmt.Fprintf(bw, "<g display=\"none\">\n")
+		fmt.Fprintf(bw, "<animate attributeName=\"display\" values=\"%s\" keyTimes=\"%s\" dur=\"%dms\" calcMode=\"discrete\" %s/>\n", values, keyTimes, total, repeat)
+		writeSVGFrame(bw, frame, renderer, opts)
+		bw.WriteString("</g>\n")
+	}
+
+	bw.WriteString("</svg>\n")
+
+	return bw.Flush()
+}
+
+func writeSVGHeader(w *bufio.Writer, opts *SVGOptions) error {
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	// pixel outlines only stay sharp without antialiasing
+	rendering := "crispEdges"
+	if opts.Smooth {
+		rendering = "geometricPrecision"
+	}
+
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 256 192\" shape-rendering=\"%s\">\n", 256*scale, 192*scale, rendering)
+	return nil
+}
+
+// writeSVGFrame draws the paper and the traced layers, layer 2 below
+// layer 1.
+func writeSVGFrame(w *bufio.Writer, f *Frame, renderer *Renderer, opts *SVGOptions) {
+	colors := renderer.palette().colors()
+	paper, layer1, layer2 := frameColorIndices(f)
+
+	if !opts.TransparentPaper {
+		fmt.Fprintf(w, "<rect width=\"256\" height=\"192\" fill=\"%s\"/>\n", svgColor(colors[paper]))
+	}
+
+	layers := []struct {
+		layer  *Layer
+		index  uint8
+		hidden bool
+	}{
+		{f.Layer2, layer2, renderer.HideLayer2},
+		{f.Layer1, layer1, renderer.HideLayer1},
+	}
+
+	for _, l := range layers {
+		if l.hidden {
+			continue
+		}
+
+		path := svgPath(traceLayer(l.layer), opts.Smooth)
+		if path == "" {
+			continue
+		}
+
+		fmt.Fprintf(w, "<path fill=\"%s\" d=\"%s\"/>\n", svgColor(colors[l.index]), path)
+	}
+}
+
+// traceLayer returns the outlines of the drawn pixels as closed loops of
+// corner points. Outlines run clockwise around drawn areas and counter
+// clockwise around holes, so they fill correctly with the nonzero rule.
+func traceLayer(layer *Layer) [][]image.Point {
+	type edge struct {
+		from, to image.Point
+		used     bool
+	}
+
+	edges := make([]edge, 0)
+	outgoing := make(map[image.Point][]int)
+
+	drawn := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < 256 && y < 192 && layer.Get(x, y)
+	}
+
+	addEdge := func(from, to image.Point) {
+		outgoing[from] = append(outgoing[from], len(edges))
+		edges = append(edges, edge{from: from, to: to})
+	}
+
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			if !drawn(x, y) {
+				continue
+			}
+
+			if !drawn(x, y-1) {
+				addEdge(image.Pt(x, y), image.Pt(x+1, y))
+			}
+			if !drawn(x+1, y) {
+				addEdge(image.Pt(x+1, y), image.Pt(x+1, y+1))
+			}
+			if !drawn(x, y+1) {
+				addEdge(image.Pt(x+1, y+1), image.Pt(x, y+1))
+			}
+			if !drawn(x-1, y) {
+				addEdge(image.Pt(x, y+1), image.Pt(x, y))
+			}
+		}
+	}
+
+	loops := make([][]image.Point, 0)
+	for i := range edges {
+		if edges[i].used {
+			continue
+		}
+
+		loop := make([]image.Point, 0)
+		current := i
+		for !edges[current].used {
+			e := &edges[current]
+			e.used = true
+			loop = append(loop, e.from)
+
+			direction := e.to.Sub(e.from)
+			next := -1
+			for _, candidate := range outgoing[e.to] {
+				if edges[candidate].used {
+					continue
+				}
+
+				// where two pixels touch diagonally, turn right so they are
+				// traced as separate shapes
+				d := edges[candidate].to.Sub(edges[candidate].from)
+				if next < 0 || d == image.Pt(-direction.Y, direction.X) {
+					next = candidate
+				}
+			}
+
+			if next < 0 {
+				break
+			}
+			current = next
+		}
+
+		loops = append(loops, simplifyLoop(loop))
+	}
+
+	return loops
+}
+
+// simplifyLoop drops the points in the middle of straight runs.
+func simplifyLoop(loop []image.Point) []image.Point {
+	res := make([]image.Point, 0, len(loop))
+
+	for i, p := range loop {
+		prev := loop[(i+len(loop)-1)%len(loop)]
+		next := loop[(i+1)%len(loop)]
+
+		if p.Sub(prev) != next.Sub(p) {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// svgPath turns loops into path data. Smoothed loops round each corner with
+// a quadratic curve of up to one pixel, or half the length of the shorter
+// edge, so steps become slopes while straight runs stay straight.
+func svgPath(loops [][]image.Point, smooth bool) string {
+	sb := &strings.Builder{}
+
+	for _, loop := range loops {
+		if len(loop) == 0 {
+			continue
+		}
+
+		if !smooth {
+			fmt.Fprintf(sb, "M%d %d", loop[0].X, loop[0].Y)
+			for _, p := range loop[1:] {
+				fmt.Fprintf(sb, "L%d %d", p.X, p.Y)
+			}
+			sb.WriteString("Z")
+			continue
+		}
+
+		// towards returns the point r pixels from p in the direction of q
+		towards := func(p, q image.Point) (float64, float64) {
+			d := q.Sub(p)
+			length := math.Abs(float64(d.X)) + math.Abs(float64(d.Y))
+			r := math.Min(length/2, 1)
+
+			return float64(p.X) + float64(d.X)*r/length, float64(p.Y) + float64(d.Y)*r/length
+		}
+
+		for i, p := range loop {
+			prev := loop[(i+len(loop)-1)%len(loop)]
+			next := loop[(i+1)%len(loop)]
+
+			command := "L"
+			if i == 0 {
+				command = "M"
+			}
+
+			ax, ay := towards(p, prev)
+			bx, by := towards(p, next)
+			fmt.Fprintf(sb, "%s%s %sQ%d %d %s %s", command, svgNumber(ax), svgNumber(ay), p.X, p.Y, svgNumber(bx), svgNumber(by))
+		}
+		sb.WriteString("Z")
+	}
+
+	return sb.String()
+}
+
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,95 @@
+package ppmlib
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestTraceLayer(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		draw func(layer *Layer)
+		want string
+	}{
+		{"filled rect", func(layer *Layer) {
+			for y := 5; y < 8; y++ {
+				for x := 10; x < 20; x++ {
+					layer.Set(x, y, true)
+				}
+			}
+		}, "M10 5L20 5L20 8L10 8Z"},
+		// the hole runs counter clockwise, so it stays empty with the
+		// nonzero rule
+		{"hole", func(layer *Layer) {
+			for y := 0; y < 6; y++ {
+				for x := 0; x < 6; x++ {
+					layer.Set(x, y, x < 2 || y < 2 || x > 3 || y > 3)
+				}
+			}
+		}, "M0 0L6 0L6 6L0 6ZM2 2L2 4L4 4L4 2Z"},
+		{"diagonal pixels", func(layer *Layer) {
+			layer.Set(0, 0, true)
+			layer.Set(1, 1, true)
+		}, "M0 0L1 0L1 1L0 1ZM1 1L2 1L2 2L1 2Z"},
+		{"empty", func(layer *Layer) {}, ""},
+	} {
+		layer := NewFrame().Layer1
+		test.draw(layer)
+
+		if got := svgPath(traceLayer(layer), false); got != test.want {
+			t.Errorf("%s: path is %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// TestTraceLayerFill fills the traced outlines of a random drawing with the
+// nonzero rule and compares them to the layer.
+func TestTraceLayerFill(t *testing.T) {
+	layer := NewFrame().Layer1
+	random := rand.New(rand.NewSource(1))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			layer.Set(x+100, y+80, random.Intn(3) == 0)
+		}
+	}
+	// a pixel on each border
+	layer.Set(0, 50, true)
+	layer.Set(255, 50, true)
+	layer.Set(50, 0, true)
+	layer.Set(50, 191, true)
+
+	// vertical edges by the row they cross
+	crossings := make([][]image.Point, 192)
+	for _, loop := range traceLayer(layer) {
+		for i, from := range loop {
+			to := loop[(i+1)%len(loop)]
+			if from.X != to.X {
+				continue
+			}
+
+			for y := minInt(from.Y, to.Y); y < maxInt(from.Y, to.Y); y++ {
+				direction := 1
+				if to.Y < from.Y {
+					direction = -1
+				}
+				crossings[y] = append(crossings[y], image.Pt(from.X, direction))
+			}
+		}
+	}
+
+	for y := 0; y < 192; y++ {
+		for x := 0; x < 256; x++ {
+			winding := 0
+			for _, crossing := range crossings[y] {
+				if crossing.X > x {
+					winding += crossing.Y
+				}
+			}
+
+			if filled := winding != 0; filled != layer.Get(x, y) {
+				t.Fatalf("pixel %d,%d is filled %v, drawn %v", x, y, filled, layer.Get(x, y))
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ppmlib
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// thumbnailPalette is the fixed 16 color palette of the 64x48 thumbnail.
+var thumbnailPalette = color.Palette{
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0x52, 0x52, 0x52, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0x9C, 0x9C, 0x9C, 0xFF},
+	color.RGBA{0xFF, 0x48, 0x44, 0xFF},
+	color.RGBA{0xC8, 0x51, 0x4F, 0xFF},
+	color.RGBA{0xFF, 0xAD, 0xAC, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+	color.RGBA{0x48, 0x40, 0xFF, 0xFF},
+	color.RGBA{0x51, 0x4F, 0xB8, 0xFF},
+	color.RGBA{0xAD, 0xAB, 0xFF, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+	color.RGBA{0xB6, 0x57, 0xB7, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+	color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+}
+
+// ThumbnailImage decodes the 64x48 thumbnail. It is stored as 8x8 tiles of
+// 4 bits per pixel, low nibble first.
+func (f *PPMFile) ThumbnailImage() (*image.Paletted, error) {
+	if len(f.Thumbnail) != 1536 {
+		return nil, errors.New("invalid thumbnail size")
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, 64, 48), thumbnailPalette)
+	offset := 0
+
+	for tileY := 0; tileY < 48; tileY += 8 {
+		for tileX := 0; tileX < 64; tileX += 8 {
+			for y := 0; y < 8; y++ {
+				row := img.Pix[(tileY+y)*img.Stride+tileX:]
+
+				for x := 0; x < 8; x += 2 {
+					b := f.Thumbnail[offset]
+					row[x], row[x+1] = b&0xF, b>>4
+					offset++
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
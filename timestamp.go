@@ -13,7 +13,7 @@ func NewTimestamp(val uint32) *Timestamp {
 }
 
 func (t Timestamp) String() string {
-	dummyTime, err := time.Parse("2006-02-01", "2000-01-01")
+	dummyTime, err := time.Parse("2006-01-02", "2000-01-01")
 	if err != nil {
 		return ""
 	}
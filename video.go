@@ -0,0 +1,172 @@
+package ppmlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type VideoOptions struct {
+	// FFmpegPath is the ffmpeg binary to run, "ffmpeg" from PATH when empty.
+	FFmpegPath string
+	Preset     VideoPreset
+	RenderOptions
+	// SampleRate of the audio passed to ffmpeg, 32768 when zero.
+	SampleRate int
+	// Args are extra output arguments, placed after the preset's.
+	Args []string
+	// Progress is called after each frame has been written to ffmpeg.
+	Progress func(frame, total int)
+}
+
+var videoPresetArgs = map[VideoPreset][]string{
+	VideoPresetH264: {"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-movflags", "frag_keyframe+empty_moov", "-f", "mp4"},
+	VideoPresetVP9:  {"-c:v", "libvpx-vp9", "-pix_fmt", "yuv420p", "-c:a", "libopus", "-f", "webm"},
+	VideoPresetFFV1: {"-c:v", "ffv1", "-c:a", "flac", "-f", "matroska"},
+}
+
+// ExportVideo encodes the flipnote with ffmpeg and writes the result to w.
+// Frames are piped to ffmpeg as raw RGBA at the exact framerate, the master
+// mix is passed in as a temporary wav file.
+func (f *PPMFile) ExportVideo(ctx context.Context, w io.Writer, opts *VideoOptions) error {
+	if opts == nil {
+		opts = &VideoOptions{}
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	presetArgs, ok := videoPresetArgs[opts.Preset]
+	if !ok {
+		return errors.New("invalid video preset")
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	ffmpegPath := opts.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	sampleRate := opts.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 32768
+	}
+
+	num, den := framerateFraction(f.Framerate)
+	width, height := 256*scale, 192*scale
+
+	args := []string{"-hide_banner", "-loglevel", "error",
+		"-f", "rawvideo",
+		"-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", width, height),
+		"-framerate", fmt.Sprintf("%d/%d", num, den),
+		"-i", "pipe:0",
+	}
+
+	if NewAudioDecoder(f).hasTrack(Master) {
+		wavPath, err := f.writeTempWav(sampleRate)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(wavPath)
+
+		args = append(args, "-i", wavPath, "-shortest")
+	}
+
+	args = append(args, presetArgs...)
+	args = append(args, opts.Args...)
+	args = append(args, "pipe:1")
+
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	cmd.Stdout = w
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	img := image.NewRGBA(renderer.Bounds())
+
+	total := len(f.Frames)
+	for i, frame := range f.Frames {
+		if _, err := renderer.RenderRGBA(frame, img); err != nil {
+			stdin.Close()
+			cmd.Wait()
+			return err
+		}
+
+		if _, err := stdin.Write(img.Pix); err != nil {
+			stdin.Close()
+			if waitErr := cmd.Wait(); waitErr != nil {
+				err = waitErr
+			}
+
+			return ffmpegError(err, stderr)
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(i+1, total)
+		}
+	}
+
+	if err := stdin.Close(); err != nil {
+		cmd.Wait()
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return ffmpegError(err, stderr)
+	}
+
+	return nil
+}
+
+func (f *PPMFile) writeTempWav(sampleRate int) (string, error) {
+	pcm, err := NewAudioDecoder(f).GetAudioMasterPcm(sampleRate)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp("", "ppmlib-*.wav")
+	if err != nil {
+		return "", err
+	}
+
+	err = encodeWav(file, pcm, sampleRate)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+func ffmpegError(err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("ffmpeg: %v: %s", err, msg)
+	}
+
+	return fmt.Errorf("ffmpeg: %v", err)
+}
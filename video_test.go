@@ -0,0 +1,215 @@
+package ppmlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeFFmpeg is put on PATH as ffmpeg. It records its arguments, the piped
+// frames and the wav input next to itself, writes "video" to stdout and
+// exits with FAKE_FFMPEG_EXIT.
+const fakeFFmpeg = `#!/bin/sh
+dir=$(dirname "$0")
+printf '%s\n' "$@" > "$dir/args"
+cat > "$dir/frames"
+prev=
+for arg in "$@"; do
+	if [ "$prev" = "-i" ] && [ "$arg" != "pipe:0" ]; then
+		cp "$arg" "$dir/audio.wav"
+	fi
+	prev=$arg
+done
+printf video
+if [ "${FAKE_FFMPEG_EXIT:-0}" != 0 ]; then
+	echo "encoder failed" >&2
+fi
+exit ${FAKE_FFMPEG_EXIT:-0}
+`
+
+func installFakeFFmpeg(t *testing.T) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(fakeFFmpeg), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return dir
+}
+
+func TestExportVideo(t *testing.T) {
+	silent := testFlipnote(6)
+	for _, track := range []PPMAudioTrack{BGM, SE1, SE2, SE3} {
+		if err := silent.Audio.setTrackData(track, []byte{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		file *PPMFile
+		opts *VideoOptions
+		args []string
+	}{
+		{
+			name: "h264",
+			file: testFlipnote(6),
+			opts: nil,
+			args: []string{"-hide_banner", "-loglevel", "error", "-f", "rawvideo", "-pix_fmt", "rgba", "-s", "256x192", "-framerate", "12/1", "-i", "pipe:0", "-i", "<wav>", "-shortest",
+				"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-movflags", "frag_keyframe+empty_moov", "-f", "mp4", "pipe:1"},
+		},
+		{
+			name: "ffv1 scaled with extra args",
+			file: testFlipnote(6),
+			opts: &VideoOptions{Preset: VideoPresetFFV1, RenderOptions: RenderOptions{Scale: 2}, SampleRate: 44100, Args: []string{"-metadata", "title=test"}},
+			args: []string{"-hide_banner", "-loglevel", "error", "-f", "rawvideo", "-pix_fmt", "rgba", "-s", "512x384", "-framerate", "12/1", "-i", "pipe:0", "-i", "<wav>", "-shortest",
+				"-c:v", "ffv1", "-c:a", "flac", "-f", "matroska", "-metadata", "title=test", "pipe:1"},
+		},
+		{
+			name: "silent",
+			file: silent,
+			opts: nil,
+			args: []string{"-hide_banner", "-loglevel", "error", "-f", "rawvideo", "-pix_fmt", "rgba", "-s", "256x192", "-framerate", "12/1", "-i", "pipe:0",
+				"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-movflags", "frag_keyframe+empty_moov", "-f", "mp4", "pipe:1"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := installFakeFFmpeg(t)
+
+			progress := 0
+			opts := &VideoOptions{}
+			if test.opts != nil {
+				*opts = *test.opts
+			}
+			opts.Progress = func(frame, total int) {
+				if frame != progress+1 || total != len(test.file.Frames) {
+					t.Errorf("progress %d/%d after frame %d", frame, total, progress)
+				}
+				progress = frame
+			}
+
+			out := &bytes.Buffer{}
+			if err := test.file.ExportVideo(context.Background(), out, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != "video" {
+				t.Errorf("stdout was not passed through, got %q", out.String())
+			}
+			if progress != len(test.file.Frames) {
+				t.Errorf("progress stopped at frame %d of %d", progress, len(test.file.Frames))
+			}
+
+			argData, err := os.ReadFile(filepath.Join(dir, "args"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := strings.Split(strings.TrimSuffix(string(argData), "\n"), "\n")
+
+			wavPath := ""
+			for i, arg := range args {
+				if i > 0 && args[i-1] == "-i" && arg != "pipe:0" {
+					wavPath = arg
+					args[i] = "<wav>"
+				}
+			}
+
+			if fmt.Sprint(args) != fmt.Sprint(test.args) {
+				t.Errorf("ffmpeg was run with\n%q\nwant\n%q", args, test.args)
+			}
+
+			checkVideoFrames(t, filepath.Join(dir, "frames"), test.file, opts)
+
+			if wavPath == "" {
+				return
+			}
+
+			if _, err := os.Stat(wavPath); !os.IsNotExist(err) {
+				t.Errorf("the temporary wav %s was not removed", wavPath)
+			}
+
+			sampleRate := opts.SampleRate
+			if sampleRate == 0 {
+				sampleRate = 32768
+			}
+
+			pcm, err := NewAudioDecoder(test.file).GetAudioMasterPcm(sampleRate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := &bytes.Buffer{}
+			if err := encodeWav(want, pcm, sampleRate); err != nil {
+				t.Fatal(err)
+			}
+
+			wav, err := os.ReadFile(filepath.Join(dir, "audio.wav"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(wav, want.Bytes()) {
+				t.Error("the wav input is not the master mix")
+			}
+		})
+	}
+}
+
+func checkVideoFrames(t *testing.T, path string, file *PPMFile, opts *VideoOptions) {
+	t.Helper()
+
+	frames, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scale, _ := exportScale(opts.Scale)
+	renderer := file.renderer(scale, opts.Palette, opts.Flags)
+	img := image.NewRGBA(renderer.Bounds())
+
+	want := &bytes.Buffer{}
+	for _, frame := range file.Frames {
+		if _, err := renderer.RenderRGBA(frame, img); err != nil {
+			t.Fatal(err)
+		}
+		want.Write(img.Pix)
+	}
+
+	if !bytes.Equal(frames, want.Bytes()) {
+		t.Errorf("piped %d bytes of frames, want the %d bytes of raw RGBA frames", len(frames), want.Len())
+	}
+}
+
+func TestExportVideoFFmpegFailure(t *testing.T) {
+	installFakeFFmpeg(t)
+	t.Setenv("FAKE_FFMPEG_EXIT", "3")
+
+	err := testFlipnote(6).ExportVideo(context.Background(), &bytes.Buffer{}, nil)
+	if err == nil {
+		t.Fatal("a failing ffmpeg returned no error")
+	}
+
+	if !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "encoder failed") {
+		t.Errorf("error %q does not carry the exit status and stderr", err)
+	}
+}
+
+func TestExportVideoInvalidPreset(t *testing.T) {
+	installFakeFFmpeg(t)
+
+	if err := testFlipnote(6).ExportVideo(context.Background(), &bytes.Buffer{}, &VideoOptions{Preset: VideoPreset(-1)}); err == nil {
+		t.Error("an invalid preset was accepted")
+	}
+}
@@ -0,0 +1,113 @@
+package ppmlib
+
+import (
+	"errors"
+	"math"
+)
+
+// waveformSampleRate is the rate tracks are decoded at for peak analysis.
+const waveformSampleRate = 32768
+
+// WaveformPeak summarizes a range of samples, normalized to -1..1.
+type WaveformPeak struct {
+	Min float32
+	Max float32
+	RMS float32
+}
+
+// WaveformPeaks splits the flipnote's timeline into evenly sized buckets and
+// returns the peaks of each. track may be Master for the mixed output. A track
+// without sound is an error.
+func (f *PPMFile) WaveformPeaks(track PPMAudioTrack, buckets int) ([]WaveformPeak, error) {
+	if buckets <= 0 {
+		return nil, errors.New("invalid bucket count")
+	}
+
+	pcm, err := f.waveformPcm(track)
+	if err != nil {
+		return nil, err
+	}
+
+	peaks := make([]WaveformPeak, buckets)
+	for i := range peaks {
+		start := i * len(pcm) / buckets
+		end := (i + 1) * len(pcm) / buckets
+		peaks[i] = waveformPeak(pcm[start:end])
+	}
+
+	return peaks, nil
+}
+
+// FrameWaveformPeaks returns one peak per frame, covering the samples that
+// play while that frame is shown.
+func (f *PPMFile) FrameWaveformPeaks(track PPMAudioTrack) ([]WaveformPeak, error) {
+	pcm, err := f.waveformPcm(track)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := NewAudioDecoder(f)
+	frameCount := int(f.FrameCount)
+
+	peaks := make([]WaveformPeak, frameCount)
+	for i := range peaks {
+		start := decoder.frameOffset(i, waveformSampleRate)
+		end := len(pcm)
+		if i+1 < frameCount {
+			end = decoder.frameOffset(i+1, waveformSampleRate)
+		}
+
+		if start > len(pcm) {
+			start = len(pcm)
+		}
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+
+		peaks[i] = waveformPeak(pcm[start:end])
+	}
+
+	return peaks, nil
+}
+
+// waveformPcm decodes a track onto the flipnote's timeline, so that every
+// track has the same length as the master mix.
+func (f *PPMFile) waveformPcm(track PPMAudioTrack) ([]int16, error) {
+	if track > Master {
+		return nil, errors.New("invalid track")
+	}
+
+	decoder := NewAudioDecoder(f)
+	if !decoder.hasTrack(track) {
+		return nil, errors.New("the track has no sound")
+	}
+
+	return decoder.GetAudioStemPcm(waveformSampleRate, track)
+}
+
+func waveformPeak(pcm []int16) WaveformPeak {
+	if len(pcm) == 0 {
+		return WaveformPeak{}
+	}
+
+	min, max := pcm[0], pcm[0]
+	var sum float64
+
+	for _, sample := range pcm {
+		if sample < min {
+			min = sample
+		}
+		if sample > max {
+			max = sample
+		}
+
+		v := float64(sample) / 32768
+		sum += v * v
+	}
+
+	return WaveformPeak{
+		Min: float32(min) / 32768,
+		Max: float32(max) / 32768,
+		RMS: float32(math.Sqrt(sum / float64(len(pcm)))),
+	}
+}
@@ -0,0 +1,94 @@
+package ppmlib
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWaveformPeaks(t *testing.T) {
+	file := testFlipnote(30)
+
+	peaks, err := file.WaveformPeaks(BGM, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(peaks) != 10 {
+		t.Fatalf("got %d buckets, want 10", len(peaks))
+	}
+
+	// the BGM is a sine of amplitude 6000 that lasts 2 of the 2.5 seconds,
+	// mixed at half volume
+	amplitude := 3000.0 / 32768
+	for i, peak := range peaks[:8] {
+		if math.Abs(float64(peak.Max)-amplitude) > 0.01 || math.Abs(float64(peak.Min)+amplitude) > 0.01 {
+			t.Errorf("bucket %d: min %v and max %v, want about ±%v", i, peak.Min, peak.Max, amplitude)
+		}
+		if math.Abs(float64(peak.RMS)-amplitude/math.Sqrt2) > 0.005 {
+			t.Errorf("bucket %d: rms %v, want about %v", i, peak.RMS, amplitude/math.Sqrt2)
+		}
+	}
+
+	if peaks[9] != (WaveformPeak{}) {
+		t.Errorf("the last bucket is after the BGM ended, got %+v", peaks[9])
+	}
+}
+
+func TestFrameWaveformPeaks(t *testing.T) {
+	file := testFlipnote(30)
+
+	peaks, err := file.FrameWaveformPeaks(SE1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(peaks) != 30 {
+		t.Fatalf("got %d peaks, want one per frame", len(peaks))
+	}
+
+	// SE1 plays on every sixth frame and lasts a quarter second, 3 frames
+	for i, peak := range peaks {
+		playing := i%6 < 3
+		if playing && peak.Max < 0.05 {
+			t.Errorf("frame %d: max %v while SE1 plays", i, peak.Max)
+		}
+		if !playing && peak != (WaveformPeak{}) {
+			t.Errorf("frame %d: got %+v while SE1 is silent", i, peak)
+		}
+	}
+}
+
+func TestWaveformPeaksErrors(t *testing.T) {
+	file := testFlipnote(6)
+
+	for _, buckets := range []int{0, -1} {
+		if _, err := file.WaveformPeaks(Master, buckets); err == nil {
+			t.Errorf("%d buckets were accepted", buckets)
+		}
+	}
+
+	if _, err := file.WaveformPeaks(SE3, 10); err == nil {
+		t.Error("the empty SE3 track was accepted")
+	}
+
+	if _, err := file.WaveformPeaks(PPMAudioTrack(9), 10); err == nil {
+		t.Error("an invalid track was accepted")
+	}
+
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	silent, err := CreateFile(author, testFrames(6), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := silent.WaveformPeaks(Master, 10); err == nil {
+		t.Error("the master of a silent flipnote was accepted")
+	}
+	if _, err := silent.FrameWaveformPeaks(Master); err == nil {
+		t.Error("the master of a silent flipnote was accepted")
+	}
+}
@@ -0,0 +1,258 @@
+package ppmlib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/RinLovesYou/ppmlib-go/utils"
+)
+
+type Y4MOptions struct {
+	RenderOptions
+	// Chroma420 subsamples chroma to 4:2:0 instead of writing full 4:4:4.
+	Chroma420 bool
+}
+
+type Y4MImportOptions struct {
+	// Author is recorded as the root, parent and current author.
+	Author *Author
+}
+
+// ExportY4M writes the flipnote as an uncompressed YUV4MPEG2 stream with
+// the flipnote's exact framerate, using limited range BT.601 colors.
+func (f *PPMFile) ExportY4M(w io.Writer, opts *Y4MOptions) error {
+	if opts == nil {
+		opts = &Y4MOptions{}
+	}
+
+	scale, err := exportScale(opts.Scale)
+	if err != nil {
+		return err
+	}
+
+	if f.Framerate <= 0 {
+		return errors.New("invalid framerate")
+	}
+
+	width, height := 256*scale, 192*scale
+	num, den := framerateFraction(f.Framerate)
+
+	chroma := "444"
+	if opts.Chroma420 {
+		chroma = "420jpeg"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C%s XCOLORRANGE=LIMITED\n", width, height, num, den, chroma)
+
+	renderer := f.renderer(scale, opts.Palette, opts.Flags)
+	for _, frame := range f.Frames {
+		img, err := renderer.RenderPaletted(frame, nil)
+		if err != nil {
+			return err
+		}
+
+		lut := make([][3]byte, len(img.Palette))
+		for i, c := range img.Palette {
+			r, g, b, _ := c.RGBA()
+			lut[i][0], lut[i][1], lut[i][2] = rgbToYuv(byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+
+		planeY := make([]byte, width*height)
+		planeU := make([]byte, width*height)
+		planeV := make([]byte, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				yuv := lut[img.Pix[y*img.Stride+x]]
+				planeY[y*width+x], planeU[y*width+x], planeV[y*width+x] = yuv[0], yuv[1], yuv[2]
+			}
+		}
+
+		if opts.Chroma420 {
+			planeU = subsample420(planeU, width, height)
+			planeV = subsample420(planeV, width, height)
+		}
+
+		bw.WriteString("FRAME\n")
+		bw.Write(planeY)
+		bw.Write(planeU)
+		bw.Write(planeV)
+	}
+
+	return bw.Flush()
+}
+
+// FromY4M builds a flipnote from an 8-bit YUV4MPEG2 stream. The flipnote
+// plays at the frame speed closest to the stream's framerate, source frames
+// are dropped or repeated to keep the timing, and each frame is converted
+// with FrameFromImage.
+func FromY4M(r io.Reader, opts *Y4MImportOptions) (*PPMFile, error) {
+	if opts == nil {
+		opts = &Y4MImportOptions{}
+	}
+
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) == 0 || fields[0] != "YUV4MPEG2" {
+		return nil, errors.New("invalid y4m signature")
+	}
+
+	width, height := 0, 0
+	rateNum, rateDen := 0, 0
+	chroma := "420jpeg"
+
+	for _, field := range fields[1:] {
+		value := field[1:]
+
+		switch field[0] {
+		case 'W':
+			width, err = strconv.Atoi(value)
+		case 'H':
+			height, err = strconv.Atoi(value)
+		case 'F':
+			_, err = fmt.Sscanf(value, "%d:%d", &rateNum, &rateDen)
+		case 'C':
+			chroma = value
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid y4m header field %q", field)
+		}
+	}
+
+	if width <= 0 || height <= 0 || rateNum <= 0 || rateDen <= 0 {
+		return nil, errors.New("y4m header is missing its size or framerate")
+	}
+
+	chromaWidth, chromaHeight := 0, 0
+	switch chroma {
+	case "444", "444alpha":
+		chromaWidth, chromaHeight = width, height
+	case "422":
+		chromaWidth, chromaHeight = (width+1)/2, height
+	case "420", "420jpeg", "420paldv", "420mpeg2":
+		chromaWidth, chromaHeight = (width+1)/2, (height+1)/2
+	case "mono":
+	default:
+		return nil, fmt.Errorf("unsupported y4m chroma format %q", chroma)
+	}
+
+	planeSize := width * height
+	chromaSize := chromaWidth * chromaHeight
+	frameSize := planeSize + 2*chromaSize
+	if chroma == "444alpha" {
+		frameSize += planeSize
+	}
+
+	srcRate := float64(rateNum) / float64(rateDen)
+	speed := nearestFrameSpeed(srcRate)
+
+	timeline := newTimeline(speed)
+	data := make([]byte, frameSize)
+
+	for index := 0; ; index++ {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(line, "FRAME") {
+			return nil, errors.New("invalid y4m frame header")
+		}
+
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, err
+		}
+
+		repeats, err := timeline.repeats(float64(index+1) / srcRate)
+		if err != nil {
+			return nil, err
+		}
+		if repeats == 0 {
+			continue
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				luma := data[y*width+x]
+				u, v := byte(128), byte(128)
+
+				if chromaSize > 0 {
+					cx := x * chromaWidth / width
+					cy := y * chromaHeight / height
+					u = data[planeSize+cy*chromaWidth+cx]
+					v = data[planeSize+chromaSize+cy*chromaWidth+cx]
+				}
+
+				r, g, b := yuvToRgb(luma, u, v)
+				img.SetRGBA(x, y, color.RGBA{r, g, b, 255})
+			}
+		}
+
+		timeline.add(FrameFromImage(img), repeats)
+	}
+
+	return createFromFrames(opts.Author, timeline.frames, speed)
+}
+
+func rgbToYuv(r, g, b byte) (byte, byte, byte) {
+	rf, gf, bf := float64(r), float64(g), float64(b)
+
+	y := 16 + (65.481*rf+128.553*gf+24.966*bf)/255
+	u := 128 + (-37.797*rf-74.203*gf+112.0*bf)/255
+	v := 128 + (112.0*rf-93.786*gf-18.214*bf)/255
+
+	return clampByte(y), clampByte(u), clampByte(v)
+}
+
+func yuvToRgb(y, u, v byte) (byte, byte, byte) {
+	yf := (float64(y) - 16) * 255 / 219
+	uf := (float64(u) - 128) * 255 / 224
+	vf := (float64(v) - 128) * 255 / 224
+
+	r := yf + 1.402*vf
+	g := yf - 0.344136*uf - 0.714136*vf
+	b := yf + 1.772*uf
+
+	return clampByte(r), clampByte(g), clampByte(b)
+}
+
+func clampByte(v float64) byte {
+	return byte(utils.Clamp(v+0.5, 0, 255))
+}
+
+func subsample420(plane []byte, width, height int) []byte {
+	chromaWidth, chromaHeight := (width+1)/2, (height+1)/2
+	res := make([]byte, 0, chromaWidth*chromaHeight)
+
+	for y := 0; y < chromaHeight; y++ {
+		for x := 0; x < chromaWidth; x++ {
+			sum, count := 0, 0
+			for dy := 0; dy < 2 && 2*y+dy < height; dy++ {
+				for dx := 0; dx < 2 && 2*x+dx < width; dx++ {
+					sum += int(plane[(2*y+dy)*width+2*x+dx])
+					count++
+				}
+			}
+
+			res = append(res, byte((sum+count/2)/count))
+		}
+	}
+
+	return res
+}
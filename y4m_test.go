@@ -0,0 +1,77 @@
+package ppmlib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testY4M returns a 4x3 monochrome stream of count frames, every frame
+// filled with a luma of its index.
+func testY4M(framerate string, count int) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "YUV4MPEG2 W4 H3 F%s Cmono\n", framerate)
+
+	for i := 0; i < count; i++ {
+		buf.WriteString("FRAME\n")
+		buf.Write(bytes.Repeat([]byte{byte(i)}, 4*3))
+	}
+
+	return buf.Bytes()
+}
+
+func TestFromY4MCopiesRepeatedFrames(t *testing.T) {
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 11 fps plays at 12, so one source frame is shown twice
+	file, err := FromY4M(bytes.NewReader(testY4M("11:1", 11)), &Y4MImportOptions{Author: author})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Frames) != 12 {
+		t.Fatalf("imported %d frames, want 12", len(file.Frames))
+	}
+
+	seen := make(map[*Frame]bool)
+	for i, frame := range file.Frames {
+		if seen[frame] {
+			t.Fatalf("frame %d is shared with an earlier frame", i)
+		}
+		seen[frame] = true
+	}
+
+	layers := make(map[*Layer]bool)
+	for i, frame := range file.Frames {
+		for _, layer := range []*Layer{frame.Layer1, frame.Layer2} {
+			if layers[layer] {
+				t.Fatalf("a layer of frame %d is shared with an earlier frame", i)
+			}
+			layers[layer] = true
+		}
+	}
+}
+
+func TestFromY4MFrameLimit(t *testing.T) {
+	author, err := NewAuthor("Tester", 0x1234567890)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream breaks after 1000 frames, which is never read once the
+	// limit has been hit
+	stream := append(testY4M("30:1", 1000), "FRAME\n"...)
+
+	_, err = FromY4M(bytes.NewReader(stream), &Y4MImportOptions{Author: author})
+	if err == nil || !strings.Contains(err.Error(), "999") {
+		t.Errorf("got error %v, want the frame limit", err)
+	}
+
+	if _, err := FromY4M(bytes.NewReader(testY4M("30:1", 999)), &Y4MImportOptions{Author: author}); err != nil {
+		t.Errorf("999 frames were rejected: %v", err)
+	}
+}